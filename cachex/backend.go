@@ -0,0 +1,779 @@
+package cachex
+
+import (
+    "context"
+    "time"
+
+    "github.com/gomodule/redigo/redis"
+)
+
+// ErrNil 缓存不存在时由Backend.Get返回的错误，与redigo的redis.ErrNil保持一致，方便调用方统一判断
+var ErrNil = redis.ErrNil
+
+// Backend 定义缓存后端接口，cachex中的所有方法最终都通过此接口读写数据
+// 所有方法接收的key均为已经添加过前缀的完整key，expire的单位为秒，小于等于0表示不过期
+type Backend interface {
+    // Get 获取缓存数据，不存在时返回ErrNil
+    Get(key string) ([]byte, error)
+    // Set 设置缓存数据
+    Set(key string, value []byte, expire int64) error
+    // SetNX 仅当key不存在时设置缓存数据，设置成功返回true
+    SetNX(key string, value []byte, expire int64) (bool, error)
+    // Del 删除缓存数据
+    Del(keys ...string) error
+    // Exists 判断key是否存在
+    Exists(key string) (bool, error)
+    // Expire 设置key的过期时间
+    Expire(key string, expire int64) error
+    // IncrBy 对key的数值增加n，并返回增加后的值
+    IncrBy(key string, n int64) (int64, error)
+    // SetBit 设置指定偏移量上的位，返回该位原来的值
+    SetBit(key string, offset int64, v int) (int, error)
+    // GetBit 获取指定偏移量上的位
+    GetBit(key string, offset int64) (int, error)
+}
+
+// ContextBackend 支持context的Backend需要实现的接口
+type ContextBackend interface {
+    // WithContext 返回一个在执行操作时使用ctx控制超时和取消的Backend
+    WithContext(ctx context.Context) Backend
+}
+
+// backendWithContext 将ctx绑定到Backend上，Backend未实现ContextBackend时原样返回
+func backendWithContext(b Backend, ctx context.Context) Backend {
+    if cb, ok := b.(ContextBackend); ok && ctx != nil {
+        return cb.WithContext(ctx)
+    }
+    return b
+}
+
+// redisBackend 基于redigo连接的Backend实现
+type redisBackend struct {
+    conn redis.Conn
+    ctx  context.Context
+}
+
+// NewRedisBackend 使用redis连接创建一个Backend，连接的关闭由调用方负责
+func NewRedisBackend(conn redis.Conn) Backend {
+    return &redisBackend{conn: conn}
+}
+
+// Conn 返回底层的redis连接
+func (b *redisBackend) Conn() redis.Conn {
+    return b.conn
+}
+
+// WithContext 返回使用ctx执行命令的Backend，ctx结束时正在执行的命令会立即返回ctx的错误
+func (b *redisBackend) WithContext(ctx context.Context) Backend {
+    return &redisBackend{conn: b.conn, ctx: ctx}
+}
+
+// do 执行redis命令，绑定了ctx时使用redis.DoContext
+func (b *redisBackend) do(cmd string, args ...interface{}) (interface{}, error) {
+    if b.ctx != nil {
+        return redis.DoContext(b.conn, b.ctx, cmd, args...)
+    }
+    return b.conn.Do(cmd, args...)
+}
+
+// eval 执行lua脚本
+func (b *redisBackend) eval(script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+    if b.ctx != nil {
+        return script.DoContext(b.ctx, b.conn, keysAndArgs...)
+    }
+    return script.Do(b.conn, keysAndArgs...)
+}
+
+// receive 读取pipeline的响应
+func (b *redisBackend) receive() (interface{}, error) {
+    if b.ctx != nil {
+        return redis.ReceiveContext(b.conn, b.ctx)
+    }
+    return b.conn.Receive()
+}
+
+func (b *redisBackend) Get(key string) ([]byte, error) {
+    return redis.Bytes(b.do("GET", key))
+}
+
+func (b *redisBackend) Set(key string, value []byte, expire int64) error {
+    var err error
+    if expire > 0 {
+        _, err = b.do("SETEX", key, expire, value)
+    } else {
+        _, err = b.do("SET", key, value)
+    }
+    return err
+}
+
+func (b *redisBackend) SetNX(key string, value []byte, expire int64) (bool, error) {
+    var rs interface{}
+    var err error
+    if expire > 0 {
+        rs, err = b.do("SET", key, value, "EX", expire, "NX")
+    } else {
+        rs, err = b.do("SET", key, value, "NX")
+    }
+    if err != nil {
+        return false, err
+    }
+    return rs != nil, nil
+}
+
+func (b *redisBackend) Del(keys ...string) error {
+    if len(keys) == 0 {
+        return nil
+    }
+    _, err := b.do("DEL", redis.Args{}.AddFlat(keys)...)
+    return err
+}
+
+func (b *redisBackend) Exists(key string) (bool, error) {
+    return redis.Bool(b.do("EXISTS", key))
+}
+
+func (b *redisBackend) Expire(key string, expire int64) error {
+    _, err := b.do("EXPIRE", key, expire)
+    return err
+}
+
+func (b *redisBackend) IncrBy(key string, n int64) (int64, error) {
+    return redis.Int64(b.do("INCRBY", key, n))
+}
+
+func (b *redisBackend) SetBit(key string, offset int64, v int) (int, error) {
+    return redis.Int(b.do("SETBIT", key, offset, v))
+}
+
+func (b *redisBackend) GetBit(key string, offset int64) (int, error) {
+    return redis.Int(b.do("GETBIT", key, offset))
+}
+
+// concurrentBackend 标记可以被多个goroutine同时使用的Backend，后台刷新等异步操作可以直接使用此类Backend
+type concurrentBackend interface {
+    concurrentSafe()
+}
+
+// providerBackend 基于Provider的Backend实现，每次操作都从Provider获取连接并在操作完成后关闭
+// 适用于Provider背后是连接池的场景，可以被多个goroutine同时使用
+type providerBackend struct {
+    provider Provider
+    ctx      context.Context
+}
+
+// NewProviderBackend 使用Provider创建一个Backend，每次操作都会获取新的连接并在使用后关闭
+func NewProviderBackend(provider Provider) Backend {
+    return &providerBackend{provider: provider}
+}
+
+func (b *providerBackend) concurrentSafe() {}
+
+// WithContext 返回使用ctx执行命令的Backend
+func (b *providerBackend) WithContext(ctx context.Context) Backend {
+    return &providerBackend{provider: b.provider, ctx: ctx}
+}
+
+// do 获取一个连接执行fn，执行完成后关闭连接
+func (b *providerBackend) do(fn func(rb *redisBackend) error) error {
+    rds := b.provider.Redis()
+    defer rds.Close()
+    return fn(&redisBackend{conn: rds, ctx: b.ctx})
+}
+
+func (b *providerBackend) Get(key string) (data []byte, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        data, err = rb.Get(key)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) Set(key string, value []byte, expire int64) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.Set(key, value, expire)
+    })
+}
+
+func (b *providerBackend) SetNX(key string, value []byte, expire int64) (ok bool, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        ok, err = rb.SetNX(key, value, expire)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) Del(keys ...string) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.Del(keys...)
+    })
+}
+
+func (b *providerBackend) Exists(key string) (ok bool, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        ok, err = rb.Exists(key)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) Expire(key string, expire int64) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.Expire(key, expire)
+    })
+}
+
+func (b *providerBackend) IncrBy(key string, n int64) (v int64, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        v, err = rb.IncrBy(key, n)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) SetBit(key string, offset int64, v int) (old int, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        old, err = rb.SetBit(key, offset, v)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) GetBit(key string, offset int64) (v int, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        v, err = rb.GetBit(key, offset)
+        return err
+    })
+    return
+}
+
+// LockBackend 支持分布式锁的Backend需要实现的接口，所有操作都需要是原子的
+type LockBackend interface {
+    // AcquireLock 当key不存在时将key设置为token，并设置过期时间，设置成功返回true
+    AcquireLock(key, token string, ttl time.Duration) (bool, error)
+    // RenewLock 当key的值为token时重新设置过期时间，设置成功返回true
+    RenewLock(key, token string, ttl time.Duration) (bool, error)
+    // ReleaseLock 当key的值为token时删除key，删除成功返回true
+    ReleaseLock(key, token string) (bool, error)
+    // ReplaceLock 当key的值为token时将key设置为value，expire为过期时间，单位：秒，小于等于0表示永不过期，设置成功返回true
+    ReplaceLock(key, token string, value []byte, expire int64) (bool, error)
+}
+
+var (
+    // renewLockScript 仅当锁的持有者为当前token时延长过期时间
+    renewLockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+    // releaseLockScript 仅当锁的持有者为当前token时删除锁
+    releaseLockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0`)
+    // replaceLockScript 仅当锁的持有者为当前token时将锁替换为指定的值
+    replaceLockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+if tonumber(ARGV[3]) > 0 then
+    redis.call("SET", KEYS[1], ARGV[2], "EX", ARGV[3])
+else
+    redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1`)
+)
+
+// lockMillis 将锁的过期时间转换为毫秒，不足1毫秒时按1毫秒处理，调用方需保证ttl大于0
+func lockMillis(ttl time.Duration) int64 {
+    ms := ttl.Milliseconds()
+    if ms < 1 {
+        ms = 1
+    }
+    return ms
+}
+
+func (b *redisBackend) AcquireLock(key, token string, ttl time.Duration) (bool, error) {
+    rs, err := b.do("SET", key, token, "PX", lockMillis(ttl), "NX")
+    if err != nil {
+        return false, err
+    }
+    return rs != nil, nil
+}
+
+func (b *redisBackend) RenewLock(key, token string, ttl time.Duration) (bool, error) {
+    return redis.Bool(b.eval(renewLockScript, key, token, lockMillis(ttl)))
+}
+
+func (b *redisBackend) ReleaseLock(key, token string) (bool, error) {
+    return redis.Bool(b.eval(releaseLockScript, key, token))
+}
+
+func (b *redisBackend) ReplaceLock(key, token string, value []byte, expire int64) (bool, error) {
+    return redis.Bool(b.eval(replaceLockScript, key, token, value, expire))
+}
+
+func (b *providerBackend) AcquireLock(key, token string, ttl time.Duration) (ok bool, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        ok, err = rb.AcquireLock(key, token, ttl)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) RenewLock(key, token string, ttl time.Duration) (ok bool, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        ok, err = rb.RenewLock(key, token, ttl)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) ReleaseLock(key, token string) (ok bool, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        ok, err = rb.ReleaseLock(key, token)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) ReplaceLock(key, token string, value []byte, expire int64) (ok bool, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        ok, err = rb.ReplaceLock(key, token, value, expire)
+        return err
+    })
+    return
+}
+
+// BatchBackend 支持批量操作的Backend需要实现的接口，未实现此接口的Backend会逐个key进行操作
+type BatchBackend interface {
+    // MGet 批量获取缓存数据，返回结果与keys一一对应，不存在的key对应的结果为nil
+    MGet(keys ...string) ([][]byte, error)
+    // MSet 批量设置缓存数据，所有key使用相同的过期时间
+    MSet(items map[string][]byte, expire int64) error
+    // MSetEach 批量设置缓存数据，每个key使用expires中对应的过期时间，未指定或者小于等于0表示不过期
+    MSetEach(items map[string][]byte, expires map[string]int64) error
+}
+
+func (b *redisBackend) MGet(keys ...string) ([][]byte, error) {
+    if len(keys) == 0 {
+        return nil, nil
+    }
+    return redis.ByteSlices(b.do("MGET", redis.Args{}.AddFlat(keys)...))
+}
+
+// MSet 使用pipeline批量写入，只需要一次网络往返
+func (b *redisBackend) MSet(items map[string][]byte, expire int64) error {
+    return b.mset(items, func(string) int64 { return expire })
+}
+
+// MSetEach 使用pipeline批量写入，只需要一次网络往返
+func (b *redisBackend) MSetEach(items map[string][]byte, expires map[string]int64) error {
+    return b.mset(items, func(key string) int64 { return expires[key] })
+}
+
+// mset 使用pipeline批量写入，expire返回每个key的过期时间
+func (b *redisBackend) mset(items map[string][]byte, expire func(key string) int64) error {
+    if len(items) == 0 {
+        return nil
+    }
+    sent := 0
+    var firstErr error
+    for key, value := range items {
+        var err error
+        if ttl := expire(key); ttl > 0 {
+            err = b.conn.Send("SETEX", key, ttl, value)
+        } else {
+            err = b.conn.Send("SET", key, value)
+        }
+        if err != nil {
+            firstErr = err
+            break
+        }
+        sent++
+    }
+    return b.drain(sent, firstErr)
+}
+
+// drain 发送pipeline中的命令并读取sent个响应，返回sendErr或者第一个响应错误
+// 发送失败时也要发送并读取已经写入缓冲区的命令，避免连接的后续命令读到错位的响应
+func (b *redisBackend) drain(sent int, sendErr error) error {
+    if err := b.conn.Flush(); err != nil {
+        return err
+    }
+    firstErr := sendErr
+    for i := 0; i < sent; i++ {
+        if _, err := b.receive(); err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    return firstErr
+}
+
+func (b *providerBackend) MGet(keys ...string) (values [][]byte, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        values, err = rb.MGet(keys...)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) MSet(items map[string][]byte, expire int64) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.MSet(items, expire)
+    })
+}
+
+func (b *providerBackend) MSetEach(items map[string][]byte, expires map[string]int64) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.MSetEach(items, expires)
+    })
+}
+
+// mget 批量获取缓存数据，Backend未实现BatchBackend时逐个获取
+func mget(b Backend, keys []string) ([][]byte, error) {
+    if bb, ok := b.(BatchBackend); ok {
+        return bb.MGet(keys...)
+    }
+    values := make([][]byte, len(keys))
+    for i, key := range keys {
+        v, err := b.Get(key)
+        if err == ErrNil {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        values[i] = v
+    }
+    return values, nil
+}
+
+// mset 批量设置缓存数据，Backend未实现BatchBackend时逐个设置
+func mset(b Backend, items map[string][]byte, expire int64) error {
+    if bb, ok := b.(BatchBackend); ok {
+        return bb.MSet(items, expire)
+    }
+    for key, value := range items {
+        if err := b.Set(key, value, expire); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// msetEach 批量设置缓存数据，每个key使用expires中对应的过期时间，Backend未实现BatchBackend时逐个设置
+func msetEach(b Backend, items map[string][]byte, expires map[string]int64) error {
+    if bb, ok := b.(BatchBackend); ok {
+        return bb.MSetEach(items, expires)
+    }
+    for key, value := range items {
+        if err := b.Set(key, value, expires[key]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// TagBackend 支持标签的Backend需要实现的接口，标签使用集合保存其关联的缓存key
+type TagBackend interface {
+    // TagAdd 将members添加到标签集合中，并确保集合的过期时间不短于expire，expire小于等于0表示永不过期
+    TagAdd(tagKey string, expire int64, members ...string) error
+    // TagPop 返回标签集合中的所有成员并删除标签集合
+    TagPop(tagKey string) ([]string, error)
+}
+
+var (
+    // tagAddScript 添加标签成员，仅在新建集合或者需要延长过期时间时设置过期时间
+    tagAddScript = redis.NewScript(1, `
+local existed = redis.call("EXISTS", KEYS[1])
+redis.call("SADD", KEYS[1], unpack(ARGV, 2))
+local ttl = tonumber(ARGV[1])
+if ttl <= 0 then
+    redis.call("PERSIST", KEYS[1])
+    return 1
+end
+local cur = redis.call("TTL", KEYS[1])
+if existed == 0 or (cur >= 0 and cur < ttl) then
+    redis.call("EXPIRE", KEYS[1], ttl)
+end
+return 1`)
+    // tagPopScript 获取标签成员并删除标签
+    tagPopScript = redis.NewScript(1, `
+local members = redis.call("SMEMBERS", KEYS[1])
+redis.call("DEL", KEYS[1])
+return members`)
+)
+
+func (b *redisBackend) TagAdd(tagKey string, expire int64, members ...string) error {
+    if len(members) == 0 {
+        return nil
+    }
+    _, err := b.eval(tagAddScript, redis.Args{}.Add(tagKey, expire).AddFlat(members)...)
+    return err
+}
+
+func (b *redisBackend) TagPop(tagKey string) ([]string, error) {
+    return redis.Strings(b.eval(tagPopScript, tagKey))
+}
+
+func (b *providerBackend) TagAdd(tagKey string, expire int64, members ...string) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.TagAdd(tagKey, expire, members...)
+    })
+}
+
+func (b *providerBackend) TagPop(tagKey string) (members []string, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        members, err = rb.TagPop(tagKey)
+        return err
+    })
+    return
+}
+
+// RateLimitBackend 支持限流的Backend需要实现的接口，所有操作都需要是原子的，时间参数单位均为毫秒
+type RateLimitBackend interface {
+    // FixedWindowLimit 固定窗口限流，key为当前窗口的key，累加n后不超过limit时允许，ttl为当前窗口的剩余时间
+    FixedWindowLimit(key string, limit, n, ttl int64) (*LimitResult, error)
+    // SlidingWindowLimit 滑动窗口（滑动日志）限流，最近window内的请求数加上n不超过limit时允许，token用于区分同一时刻的请求
+    SlidingWindowLimit(key string, limit, n, window, now int64, token string) (*LimitResult, error)
+    // TokenBucketLimit 令牌桶限流，桶容量为burst，每秒补充rate个令牌，桶中令牌数不少于n时允许
+    TokenBucketLimit(key string, rate float64, burst, n, now int64) (*LimitResult, error)
+}
+
+var (
+    // fixedWindowScript 固定窗口计数，超出限制的请求不计入窗口
+    fixedWindowScript = redis.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if count + n > limit then
+    local ttl = redis.call("PTTL", KEYS[1])
+    if ttl < 0 then
+        ttl = tonumber(ARGV[3])
+    end
+    return {0, limit - count, ttl}
+end
+count = redis.call("INCRBY", KEYS[1], n)
+if count == n then
+    redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return {1, limit - count, 0}`)
+    // slidingWindowScript 使用有序集合记录窗口内每个请求的时间，被拒绝的请求不记录
+    slidingWindowScript = redis.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count + n > limit then
+    local idx = count + n - limit - 1
+    local oldest = redis.call("ZRANGE", KEYS[1], idx, idx, "WITHSCORES")
+    local retry = window
+    if oldest[2] then
+        retry = tonumber(oldest[2]) + window - now
+    end
+    return {0, limit - count, retry}
+end
+for i = 1, n do
+    redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, limit - count - n, 0}`)
+    // tokenBucketScript 令牌桶，使用哈希保存桶中的令牌数以及上次补充令牌的时间
+    tokenBucketScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+    tokens = burst
+    ts = now
+end
+if now > ts then
+    tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+    ts = now
+end
+local allowed = 0
+local retry = 0
+if tokens >= n then
+    tokens = tokens - n
+    allowed = 1
+else
+    retry = math.ceil((n - tokens) * 1000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", ts)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}`)
+)
+
+// limitResult 解析限流脚本的返回值：是否允许、剩余次数、需要等待的毫秒数
+func limitResult(limit int64, reply interface{}, err error) (*LimitResult, error) {
+    values, err := redis.Int64s(reply, err)
+    if err != nil {
+        return nil, err
+    }
+    if len(values) != 3 {
+        return nil, errLimitReply
+    }
+    return newLimitResult(values[0] == 1, limit, values[1], values[2]), nil
+}
+
+func (b *redisBackend) FixedWindowLimit(key string, limit, n, ttl int64) (*LimitResult, error) {
+    reply, err := b.eval(fixedWindowScript, key, limit, n, ttl)
+    return limitResult(limit, reply, err)
+}
+
+func (b *redisBackend) SlidingWindowLimit(key string, limit, n, window, now int64, token string) (*LimitResult, error) {
+    reply, err := b.eval(slidingWindowScript, key, limit, n, window, now, token)
+    return limitResult(limit, reply, err)
+}
+
+func (b *redisBackend) TokenBucketLimit(key string, rate float64, burst, n, now int64) (*LimitResult, error) {
+    reply, err := b.eval(tokenBucketScript, key, rate, burst, n, now)
+    return limitResult(burst, reply, err)
+}
+
+func (b *providerBackend) FixedWindowLimit(key string, limit, n, ttl int64) (rs *LimitResult, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        rs, err = rb.FixedWindowLimit(key, limit, n, ttl)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) SlidingWindowLimit(key string, limit, n, window, now int64, token string) (rs *LimitResult, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        rs, err = rb.SlidingWindowLimit(key, limit, n, window, now, token)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) TokenBucketLimit(key string, rate float64, burst, n, now int64) (rs *LimitResult, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        rs, err = rb.TokenBucketLimit(key, rate, burst, n, now)
+        return err
+    })
+    return
+}
+
+// 位运算的类型
+const (
+    BitOpAnd = "AND"
+    BitOpOr  = "OR"
+    BitOpXor = "XOR"
+)
+
+// BitmapBackend 支持批量位操作的Backend需要实现的接口
+type BitmapBackend interface {
+    // SetBits 将offsets对应的位都设置为1，expire大于0时同时设置过期时间，单位：秒
+    SetBits(key string, offsets []int64, expire int64) error
+    // GetBits 获取offsets对应的位，返回结果与offsets一一对应
+    GetBits(key string, offsets []int64) ([]int, error)
+    // BitCount 统计值为1的位的数量
+    BitCount(key string) (int64, error)
+    // BitOp 对keys进行位运算，结果保存到destKey中，op为BitOpAnd、BitOpOr或BitOpXor
+    // expire大于0时同时设置destKey的过期时间，单位：秒
+    BitOp(op, destKey string, expire int64, keys ...string) error
+}
+
+// SetBits 使用pipeline批量设置，只需要一次网络往返
+func (b *redisBackend) SetBits(key string, offsets []int64, expire int64) error {
+    if len(offsets) == 0 {
+        return nil
+    }
+    sent := 0
+    for _, offset := range offsets {
+        if err := b.conn.Send("SETBIT", key, offset, 1); err != nil {
+            return b.drain(sent, err)
+        }
+        sent++
+    }
+    if expire > 0 {
+        if err := b.conn.Send("EXPIRE", key, expire); err != nil {
+            return b.drain(sent, err)
+        }
+        sent++
+    }
+    return b.drain(sent, nil)
+}
+
+// GetBits 使用pipeline批量获取，只需要一次网络往返
+func (b *redisBackend) GetBits(key string, offsets []int64) ([]int, error) {
+    if len(offsets) == 0 {
+        return nil, nil
+    }
+    for i, offset := range offsets {
+        if err := b.conn.Send("GETBIT", key, offset); err != nil {
+            return nil, b.drain(i, err)
+        }
+    }
+    if err := b.conn.Flush(); err != nil {
+        return nil, err
+    }
+    var firstErr error
+    values := make([]int, len(offsets))
+    for i := range offsets {
+        v, err := redis.Int(b.receive())
+        if err != nil && firstErr == nil {
+            firstErr = err
+        }
+        values[i] = v
+    }
+    if firstErr != nil {
+        return nil, firstErr
+    }
+    return values, nil
+}
+
+func (b *redisBackend) BitCount(key string) (int64, error) {
+    return redis.Int64(b.do("BITCOUNT", key))
+}
+
+// BitOp 需要设置过期时间时与EXPIRE在同一个pipeline中发送
+func (b *redisBackend) BitOp(op, destKey string, expire int64, keys ...string) error {
+    if expire <= 0 {
+        _, err := b.do("BITOP", redis.Args{}.Add(op, destKey).AddFlat(keys)...)
+        return err
+    }
+    if err := b.conn.Send("BITOP", redis.Args{}.Add(op, destKey).AddFlat(keys)...); err != nil {
+        return b.drain(0, err)
+    }
+    if err := b.conn.Send("EXPIRE", destKey, expire); err != nil {
+        return b.drain(1, err)
+    }
+    return b.drain(2, nil)
+}
+
+func (b *providerBackend) SetBits(key string, offsets []int64, expire int64) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.SetBits(key, offsets, expire)
+    })
+}
+
+func (b *providerBackend) GetBits(key string, offsets []int64) (values []int, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        values, err = rb.GetBits(key, offsets)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) BitCount(key string) (n int64, err error) {
+    err = b.do(func(rb *redisBackend) error {
+        n, err = rb.BitCount(key)
+        return err
+    })
+    return
+}
+
+func (b *providerBackend) BitOp(op, destKey string, expire int64, keys ...string) error {
+    return b.do(func(rb *redisBackend) error {
+        return rb.BitOp(op, destKey, expire, keys...)
+    })
+}
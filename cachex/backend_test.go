@@ -0,0 +1,323 @@
+package cachex
+
+import (
+    "sort"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+    "github.com/gomodule/redigo/redis"
+    "github.com/whencome/goutil/timeutil"
+)
+
+// miniredisProvider 连接miniredis的Provider
+type miniredisProvider struct {
+    addr string
+}
+
+func (p miniredisProvider) Redis() redis.Conn {
+    conn, err := redis.Dial("tcp", p.addr)
+    if err != nil {
+        panic(err)
+    }
+    return conn
+}
+
+// newTestProvider 启动一个miniredis并返回连接它的Provider，测试结束时自动关闭
+func newTestProvider(t *testing.T) (*miniredis.Miniredis, Provider) {
+    mr := miniredis.RunT(t)
+    return mr, miniredisProvider{addr: mr.Addr()}
+}
+
+// testBackends 返回需要测试的Backend：内存实现以及连接miniredis的redis实现
+func testBackends(t *testing.T) (map[string]Backend, *miniredis.Miniredis) {
+    mr, provider := newTestProvider(t)
+    return map[string]Backend{
+        "memory": NewMemoryBackend(0),
+        "redis":  NewProviderBackend(provider),
+    }, mr
+}
+
+func TestLockBackendScripts(t *testing.T) {
+    backends, _ := testBackends(t)
+    for name, b := range backends {
+        t.Run(name, func(t *testing.T) {
+            lb := b.(LockBackend)
+            if ok, err := lb.AcquireLock("lock:1", "a", time.Minute); err != nil || !ok {
+                t.Fatalf("lock should be acquired: %v, %v", ok, err)
+            }
+            if ok, _ := lb.AcquireLock("lock:1", "b", time.Minute); ok {
+                t.Fatal("lock should not be acquired twice")
+            }
+            // 只有持有者才能续期、释放和替换
+            if ok, _ := lb.RenewLock("lock:1", "b", time.Minute); ok {
+                t.Fatal("lock should not be renewed by other token")
+            }
+            if ok, err := lb.RenewLock("lock:1", "a", time.Minute); err != nil || !ok {
+                t.Fatalf("lock should be renewed: %v, %v", ok, err)
+            }
+            if ok, _ := lb.ReleaseLock("lock:1", "b"); ok {
+                t.Fatal("lock should not be released by other token")
+            }
+            if ok, _ := lb.ReplaceLock("lock:1", "b", []byte("x"), 60); ok {
+                t.Fatal("lock should not be replaced by other token")
+            }
+            if ok, err := lb.ReplaceLock("lock:1", "a", []byte("done"), 60); err != nil || !ok {
+                t.Fatalf("lock should be replaced: %v, %v", ok, err)
+            }
+            if v, _ := b.Get("lock:1"); string(v) != "done" {
+                t.Fatalf("unexpected value: %q", v)
+            }
+            if ok, _ := lb.ReleaseLock("lock:1", "a"); ok {
+                t.Fatal("replaced lock should not be released by the old token")
+            }
+            if ok, err := lb.AcquireLock("lock:2", "a", time.Minute); err != nil || !ok {
+                t.Fatalf("lock should be acquired: %v, %v", ok, err)
+            }
+            if ok, err := lb.ReleaseLock("lock:2", "a"); err != nil || !ok {
+                t.Fatalf("lock should be released: %v, %v", ok, err)
+            }
+            if ok, _ := b.Exists("lock:2"); ok {
+                t.Fatal("released lock should be deleted")
+            }
+        })
+    }
+}
+
+func TestBatchBackendPipeline(t *testing.T) {
+    backends, mr := testBackends(t)
+    for name, b := range backends {
+        t.Run(name, func(t *testing.T) {
+            bb := b.(BatchBackend)
+            if err := bb.MSet(map[string][]byte{"batch:1": []byte("a"), "batch:2": []byte("b")}, 60); err != nil {
+                t.Fatal(err)
+            }
+            err := bb.MSetEach(map[string][]byte{"batch:3": []byte("c"), "batch:4": []byte("d")},
+                map[string]int64{"batch:3": 30})
+            if err != nil {
+                t.Fatal(err)
+            }
+            values, err := bb.MGet("batch:1", "batch:2", "batch:3", "batch:4", "batch:5")
+            if err != nil || len(values) != 5 {
+                t.Fatalf("unexpected values: %q, %v", values, err)
+            }
+            for i, expect := range []string{"a", "b", "c", "d"} {
+                if string(values[i]) != expect {
+                    t.Fatalf("unexpected value %d: %q", i, values[i])
+                }
+            }
+            if values[4] != nil {
+                t.Fatalf("missing key should be nil, got %q", values[4])
+            }
+            // pipeline之后连接仍然可以正常使用
+            if v, err := b.Get("batch:2"); err != nil || string(v) != "b" {
+                t.Fatalf("unexpected value: %q, %v", v, err)
+            }
+        })
+    }
+    if ttl := mr.TTL("batch:1"); ttl != time.Minute {
+        t.Fatalf("unexpected ttl of batch:1: %s", ttl)
+    }
+    if ttl := mr.TTL("batch:3"); ttl != time.Second*30 {
+        t.Fatalf("unexpected ttl of batch:3: %s", ttl)
+    }
+    if ttl := mr.TTL("batch:4"); ttl != 0 {
+        t.Fatalf("batch:4 should not expire, got %s", ttl)
+    }
+}
+
+func TestRedisBackendPipelineReuse(t *testing.T) {
+    _, provider := newTestProvider(t)
+    conn := provider.Redis()
+    defer conn.Close()
+    b := NewRedisBackend(conn).(*redisBackend)
+    // 同一个连接上连续执行多次pipeline，响应不能错位
+    for i := 0; i < 3; i++ {
+        if err := b.MSet(map[string][]byte{"reuse:1": []byte("a"), "reuse:2": []byte("b")}, 0); err != nil {
+            t.Fatal(err)
+        }
+        if v, err := b.Get("reuse:2"); err != nil || string(v) != "b" {
+            t.Fatalf("unexpected value: %q, %v", v, err)
+        }
+    }
+}
+
+func TestTagBackendScripts(t *testing.T) {
+    backends, mr := testBackends(t)
+    for name, b := range backends {
+        t.Run(name, func(t *testing.T) {
+            tb := b.(TagBackend)
+            if err := tb.TagAdd("tags:1", 30, "a", "b"); err != nil {
+                t.Fatal(err)
+            }
+            // 再次添加时只会延长过期时间
+            if err := tb.TagAdd("tags:1", 60, "c"); err != nil {
+                t.Fatal(err)
+            }
+            if err := tb.TagAdd("tags:1", 10, "a"); err != nil {
+                t.Fatal(err)
+            }
+            members, err := tb.TagPop("tags:1")
+            sort.Strings(members)
+            if err != nil || strings.Join(members, ",") != "a,b,c" {
+                t.Fatalf("unexpected members: %v, %v", members, err)
+            }
+            if members, _ = tb.TagPop("tags:1"); len(members) != 0 {
+                t.Fatalf("tag should be removed after pop, got %v", members)
+            }
+            if err = tb.TagAdd("tags:2", 30, "a"); err != nil {
+                t.Fatal(err)
+            }
+            if err = tb.TagAdd("tags:2", 0, "b"); err != nil {
+                t.Fatal(err)
+            }
+        })
+    }
+    if err := NewProviderBackend(miniredisProvider{addr: mr.Addr()}).(TagBackend).TagAdd("tags:3", 30, "a"); err != nil {
+        t.Fatal(err)
+    }
+    _ = NewProviderBackend(miniredisProvider{addr: mr.Addr()}).(TagBackend).TagAdd("tags:3", 60, "b")
+    if ttl := mr.TTL("tags:3"); ttl != time.Minute {
+        t.Fatalf("tag ttl should be extended, got %s", ttl)
+    }
+    // 过期时间小于等于0表示永不过期
+    if ttl := mr.TTL("tags:2"); ttl != 0 {
+        t.Fatalf("tag should not expire, got %s", ttl)
+    }
+}
+
+func TestRateLimitBackendScripts(t *testing.T) {
+    backends, _ := testBackends(t)
+    for name, b := range backends {
+        t.Run(name, func(t *testing.T) {
+            lb := b.(RateLimitBackend)
+            // 固定窗口：超出限制的请求不计入窗口
+            for i := 0; i < 2; i++ {
+                rs, err := lb.FixedWindowLimit("limit:fixed", 2, 1, 1000)
+                if err != nil || !rs.Allowed || rs.Remaining != int64(1-i) {
+                    t.Fatalf("request %d should be allowed: %+v, %v", i, rs, err)
+                }
+            }
+            rs, err := lb.FixedWindowLimit("limit:fixed", 2, 1, 1000)
+            if err != nil || rs.Allowed || rs.RetryAfter <= 0 || rs.RetryAfter > time.Second {
+                t.Fatalf("request should be rejected: %+v, %v", rs, err)
+            }
+
+            // 内存实现按当前时间计算过期，使用真实的时间戳
+            now := time.Now().UnixMilli()
+            // 滑动窗口：窗口为100毫秒，最多2次
+            if rs, err = lb.SlidingWindowLimit("limit:sliding", 2, 1, 100, now, "a"); err != nil || !rs.Allowed {
+                t.Fatalf("request should be allowed: %+v, %v", rs, err)
+            }
+            if rs, _ = lb.SlidingWindowLimit("limit:sliding", 2, 1, 100, now+50, "b"); !rs.Allowed || rs.Remaining != 0 {
+                t.Fatalf("request should be allowed: %+v", rs)
+            }
+            rs, _ = lb.SlidingWindowLimit("limit:sliding", 2, 1, 100, now+80, "c")
+            if rs.Allowed || rs.RetryAfter != time.Millisecond*20 {
+                t.Fatalf("request should be rejected until the oldest request leaves: %+v", rs)
+            }
+            if rs, _ = lb.SlidingWindowLimit("limit:sliding", 2, 1, 100, now+100, "d"); !rs.Allowed {
+                t.Fatalf("request should be allowed after the oldest request leaves: %+v", rs)
+            }
+
+            // 令牌桶：容量为2，每秒补充10个令牌
+            if rs, err = lb.TokenBucketLimit("limit:bucket", 10, 2, 2, now); err != nil || !rs.Allowed || rs.Remaining != 0 {
+                t.Fatalf("burst should be allowed: %+v, %v", rs, err)
+            }
+            rs, _ = lb.TokenBucketLimit("limit:bucket", 10, 2, 1, now+50)
+            if rs.Allowed || rs.RetryAfter != time.Millisecond*50 {
+                t.Fatalf("request should wait for the next token: %+v", rs)
+            }
+            if rs, _ = lb.TokenBucketLimit("limit:bucket", 10, 2, 1, now+100); !rs.Allowed {
+                t.Fatalf("request should be allowed after refill: %+v", rs)
+            }
+        })
+    }
+}
+
+func TestLimiterRedis(t *testing.T) {
+    _, provider := newTestProvider(t)
+    b := NewProviderBackend(provider)
+    for name, l := range map[string]Limiter{
+        "fixed":   NewFixedWindowLimiter(b, 3, time.Minute),
+        "sliding": NewSlidingWindowLimiter(b, 3, time.Minute),
+        "bucket":  NewTokenBucketLimiter(b, 0.01, 3),
+    } {
+        for i := 0; i < 3; i++ {
+            if rs, err := l.Allow(name); err != nil || !rs.Allowed {
+                t.Fatalf("%s: request %d should be allowed: %+v, %v", name, i, rs, err)
+            }
+        }
+        if rs, err := l.Allow(name); err != nil || rs.Allowed {
+            t.Fatalf("%s: request should be rejected: %+v, %v", name, rs, err)
+        }
+    }
+}
+
+func TestBitmapBackendPipeline(t *testing.T) {
+    backends, mr := testBackends(t)
+    for name, b := range backends {
+        t.Run(name, func(t *testing.T) {
+            bb := b.(BitmapBackend)
+            if err := bb.SetBits("bits:1", []int64{1, 7, 100}, 60); err != nil {
+                t.Fatal(err)
+            }
+            if err := bb.SetBits("bits:2", []int64{7, 8}, 0); err != nil {
+                t.Fatal(err)
+            }
+            bits, err := bb.GetBits("bits:1", []int64{0, 1, 7, 100, 1000})
+            if err != nil || len(bits) != 5 || bits[0] != 0 || bits[1] != 1 || bits[2] != 1 || bits[3] != 1 || bits[4] != 0 {
+                t.Fatalf("unexpected bits: %v, %v", bits, err)
+            }
+            if err = bb.BitOp(BitOpAnd, "bits:and", 30, "bits:1", "bits:2"); err != nil {
+                t.Fatal(err)
+            }
+            if n, err := bb.BitCount("bits:and"); err != nil || n != 1 {
+                t.Fatalf("unexpected count: %d, %v", n, err)
+            }
+            if err = bb.BitOp(BitOpOr, "bits:or", 0, "bits:1", "bits:2"); err != nil {
+                t.Fatal(err)
+            }
+            if n, err := bb.BitCount("bits:or"); err != nil || n != 4 {
+                t.Fatalf("unexpected count: %d, %v", n, err)
+            }
+            // pipeline之后连接仍然可以正常使用
+            if v, err := b.GetBit("bits:2", 8); err != nil || v != 1 {
+                t.Fatalf("unexpected bit: %d, %v", v, err)
+            }
+        })
+    }
+    if ttl := mr.TTL("bits:1"); ttl != time.Minute {
+        t.Fatalf("unexpected ttl of bits:1: %s", ttl)
+    }
+    if ttl := mr.TTL("bits:and"); ttl != time.Second*30 {
+        t.Fatalf("unexpected ttl of bits:and: %s", ttl)
+    }
+    if ttl := mr.TTL("bits:or"); ttl != 0 {
+        t.Fatalf("bits:or should not expire, got %s", ttl)
+    }
+}
+
+func TestDailyActiveRedis(t *testing.T) {
+    mr, provider := newTestProvider(t)
+    d := NewDailyActive(NewProviderBackend(provider), "login", 0)
+    day1 := time.Now()
+    day2 := day1.AddDate(0, 0, 1)
+    _ = d.Mark(1, day1)
+    _ = d.Mark(2, day1)
+    _ = d.Mark(2, day2)
+    tr := timeutil.TimeRange{StartTime: day1, EndTime: day2.Add(time.Second)}
+    if n, err := d.CountAny(tr); err != nil || n != 2 {
+        t.Fatalf("expect 2 users active in range, got %d, %v", n, err)
+    }
+    if n, err := d.CountAll(tr); err != nil || n != 1 {
+        t.Fatalf("expect 1 user active every day, got %d, %v", n, err)
+    }
+    // 临时结果在统计后删除
+    for _, k := range mr.Keys() {
+        if strings.Contains(k, ":tmp:") {
+            t.Fatalf("temporary key %s should be removed", k)
+        }
+    }
+}
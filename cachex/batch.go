@@ -0,0 +1,206 @@
+package cachex
+
+import (
+    "errors"
+    "reflect"
+    "time"
+
+    "github.com/gomodule/redigo/redis"
+    "github.com/whencome/goutil"
+)
+
+var (
+    // ErrInvalidBatchTarget 批量操作的结果对象不是指向map[string]T的非nil指针
+    ErrInvalidBatchTarget = errors.New("cachex: batch target must be a non-nil pointer to map[string]T")
+    // ErrBatchOptionUnsupported 批量调用不支持传入的选项
+    ErrBatchOptionUnsupported = errors.New("cachex: option is not supported by batch calls")
+)
+
+// BatchBizFunc 定义批量加载数据的业务方法，keys为缓存中不存在的key，返回key对应的数据，不存在的数据不需要返回
+type BatchBizFunc func(keys []string) (map[string]interface{}, error)
+
+// batchTarget 检查并初始化批量操作的结果对象
+func batchTarget(ret interface{}) (reflect.Value, error) {
+    rv := reflect.ValueOf(ret)
+    if rv.Kind() != reflect.Ptr || rv.IsNil() {
+        return reflect.Value{}, ErrInvalidBatchTarget
+    }
+    mv := rv.Elem()
+    if mv.Kind() != reflect.Map || mv.Type().Key().Kind() != reflect.String {
+        return reflect.Value{}, ErrInvalidBatchTarget
+    }
+    if mv.IsNil() {
+        mv.Set(reflect.MakeMap(mv.Type()))
+    }
+    return mv, nil
+}
+
+// setBatchValue 解码数据并写入结果对象
+func setBatchValue(mv reflect.Value, key string, data []byte) error {
+    data, _ = unwrapEntry(data)
+    elem := reflect.New(mv.Type().Elem())
+    if err := decodeValue(data, elem.Interface()); err != nil {
+        return err
+    }
+    mv.SetMapIndex(reflect.ValueOf(key).Convert(mv.Type().Key()), elem.Elem())
+    return nil
+}
+
+// FetchMany 批量从缓存中取值，ret必须是指向map[string]T的指针，返回缓存中不存在的key
+// 缓存的是空结果的key既不会写入ret，也不会出现在返回的key列表中
+func FetchMany(ret interface{}, rds redis.Conn, cacheKeys []string) ([]string, error) {
+    return BFetchMany(ret, NewRedisBackend(rds), cacheKeys)
+}
+
+// PFetchMany 批量从缓存中取值
+func PFetchMany(ret interface{}, provider Provider, cacheKeys []string) ([]string, error) {
+    rds := provider.Redis()
+    defer rds.Close()
+    return FetchMany(ret, rds, cacheKeys)
+}
+
+// BFetchMany 批量从缓存中取值
+func BFetchMany(ret interface{}, b Backend, cacheKeys []string) ([]string, error) {
+    mv, err := batchTarget(ret)
+    if err != nil {
+        return nil, err
+    }
+    if len(cacheKeys) == 0 {
+        return nil, nil
+    }
+    fullKeys := make([]string, len(cacheKeys))
+    for i, cacheKey := range cacheKeys {
+        fullKeys[i] = getCacheKey(cacheKey)
+    }
+    values, err := mget(b, fullKeys)
+    if err != nil {
+        return nil, err
+    }
+    misses := make([]string, 0)
+    for i, cacheKey := range cacheKeys {
+        data := values[i]
+        if data == nil {
+            emit(EventMiss, fullKeys[i], 0, nil)
+            misses = append(misses, cacheKey)
+            continue
+        }
+        emitHit(nil, fullKeys[i], SourceBackend)
+        if isNotFound(data) {
+            continue
+        }
+        // 无法解码的数据视为不存在
+        if err = setBatchValue(mv, cacheKey, data); err != nil {
+            misses = append(misses, cacheKey)
+        }
+    }
+    return misses, nil
+}
+
+// CallMany 带有缓存的批量调用，ret必须是指向map[string]T的指针
+// 先批量从缓存中取值，仅对缓存中不存在的key调用业务方法，并将结果批量写入缓存
+// 通过WithNotFoundTTL可以缓存业务方法未返回的key，WithCodec可以指定编码格式
+func CallMany(ret interface{}, rds redis.Conn, cacheKeys []string, expire int64, bf BatchBizFunc, opts ...CallOption) error {
+    return BCallMany(ret, NewRedisBackend(rds), cacheKeys, expire, bf, opts...)
+}
+
+// PCallMany 带有缓存的批量调用
+func PCallMany(ret interface{}, provider Provider, cacheKeys []string, expire int64, bf BatchBizFunc, opts ...CallOption) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return CallMany(ret, rds, cacheKeys, expire, bf, opts...)
+}
+
+// BCallMany 带有缓存的批量调用
+// 不支持WithSingleflight、WithLoadLock、WithStaleWhileRevalidate、WithEarlyRefresh、WithLocalCache以及WithBloomFilter，
+// 传入时返回ErrBatchOptionUnsupported
+func BCallMany(ret interface{}, b Backend, cacheKeys []string, expire int64, bf BatchBizFunc, opts ...CallOption) error {
+    o := newCallOptions(opts)
+    if o.singleflight || o.loadLock || o.softExpire > 0 || o.earlyBeta > 0 || o.local || o.bloom != nil {
+        return ErrBatchOptionUnsupported
+    }
+    if err := checkTags(b, o.tags); err != nil {
+        return err
+    }
+    if o.ctx != nil {
+        if err := o.ctx.Err(); err != nil {
+            return err
+        }
+        b = backendWithContext(b, o.ctx)
+    }
+    misses, err := BFetchMany(ret, b, cacheKeys)
+    if err != nil {
+        return err
+    }
+    if len(misses) == 0 {
+        return nil
+    }
+    mv, _ := batchTarget(ret)
+
+    // 仅加载缓存中不存在的数据，耗时平均分摊到每个key上
+    start := time.Now()
+    data, err := bf(misses)
+    cost := time.Since(start) / time.Duration(len(misses))
+    for _, cacheKey := range misses {
+        if err != nil {
+            o.emit(EventLoadError, getCacheKey(cacheKey), cost, err)
+        } else {
+            o.emit(EventLoad, getCacheKey(cacheKey), cost, nil)
+        }
+    }
+    if err != nil {
+        return err
+    }
+    // 每个key单独计算过期时间的随机抖动，空结果的标记也一起写入，只需要一次批量写入
+    items := make(map[string][]byte, len(misses))
+    expires := make(map[string]int64, len(misses))
+    storedKeys := make([]string, 0, len(misses))
+    notFoundKeys := make([]string, 0)
+    var maxTTL int64
+    for _, cacheKey := range misses {
+        key := getCacheKey(cacheKey)
+        v, ok := data[cacheKey]
+        if !ok || goutil.IsNil(v) {
+            if o.notFoundExpire > 0 {
+                items[key] = notFoundMarker
+                expires[key] = o.notFoundExpire
+                notFoundKeys = append(notFoundKeys, key)
+            }
+            continue
+        }
+        bytesData, err := encodeValue(o.codec, v)
+        if err != nil {
+            return err
+        }
+        if err = setBatchValue(mv, cacheKey, bytesData); err != nil {
+            return err
+        }
+        ttl := o.jitterExpire(expire)
+        if ttl > maxTTL {
+            maxTTL = ttl
+        }
+        items[key] = bytesData
+        expires[key] = ttl
+        storedKeys = append(storedKeys, key)
+    }
+    if len(items) == 0 {
+        return nil
+    }
+
+    // 缓存数据，写入失败不影响返回结果，仅通知观察者
+    if err = msetEach(b, items, expires); err != nil {
+        for key := range items {
+            o.emit(EventStoreError, key, 0, err)
+        }
+        return nil
+    }
+    invalidateLocal(append(storedKeys, notFoundKeys...)...)
+    if len(o.tags) > 0 {
+        if len(storedKeys) > 0 {
+            _ = addTags(b, o.tags, maxTTL, storedKeys...)
+        }
+        if len(notFoundKeys) > 0 {
+            _ = addTags(b, o.tags, o.notFoundExpire, notFoundKeys...)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,150 @@
+package cachex
+
+import (
+    "sort"
+    "strconv"
+    "testing"
+    "time"
+)
+
+type batchUser struct {
+    ID   int
+    Name string
+}
+
+func TestBCallMany(t *testing.T) {
+    b := NewMemoryBackend(0)
+    _ = BStoreMany(b, 60, map[string]interface{}{
+        "u:1": batchUser{ID: 1, Name: "a"},
+        "u:2": batchUser{ID: 2, Name: "b"},
+    })
+    var loaded []string
+    bf := func(keys []string) (map[string]interface{}, error) {
+        loaded = append(loaded, keys...)
+        rs := make(map[string]interface{})
+        for _, k := range keys {
+            if k == "u:3" {
+                rs[k] = batchUser{ID: 3, Name: "c"}
+            }
+        }
+        return rs, nil
+    }
+    var ret map[string]batchUser
+    keys := []string{"u:1", "u:2", "u:3", "u:4"}
+    if err := BCallMany(&ret, b, keys, 60, bf, WithNotFoundTTL(10)); err != nil {
+        t.Fatal(err)
+    }
+    sort.Strings(loaded)
+    if len(loaded) != 2 || loaded[0] != "u:3" || loaded[1] != "u:4" {
+        t.Fatalf("only missing keys should be loaded, got %v", loaded)
+    }
+    if len(ret) != 3 || ret["u:3"].Name != "c" {
+        t.Fatalf("unexpected result: %v", ret)
+    }
+
+    // 再次调用时全部命中缓存（包括空结果）
+    loaded = nil
+    ret = nil
+    if err := BCallMany(&ret, b, keys, 60, bf, WithNotFoundTTL(10)); err != nil {
+        t.Fatal(err)
+    }
+    if len(loaded) != 0 || len(ret) != 3 {
+        t.Fatalf("unexpected result: %v, loaded: %v", ret, loaded)
+    }
+
+    BRemoveBatch(b, []string{"u:1", "u:2"})
+    ret = nil
+    misses, err := BFetchMany(&ret, b, keys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(misses) != 2 || len(ret) != 1 {
+        t.Fatalf("unexpected fetch result: %v, misses: %v", ret, misses)
+    }
+    if _, err = BFetchMany(ret, b, keys); err != ErrInvalidBatchTarget {
+        t.Fatalf("expect ErrInvalidBatchTarget, got %v", err)
+    }
+}
+
+func TestBCallManyJitter(t *testing.T) {
+    b := NewMemoryBackend(0)
+    keys := make([]string, 50)
+    for i := range keys {
+        keys[i] = "jitter:" + strconv.Itoa(i)
+    }
+    bf := func(keys []string) (map[string]interface{}, error) {
+        rs := make(map[string]interface{})
+        for _, k := range keys {
+            rs[k] = k
+        }
+        return rs, nil
+    }
+    var ret map[string]string
+    if err := BCallMany(&ret, b, keys, 1000, bf, WithExpireJitter(0.5)); err != nil {
+        t.Fatal(err)
+    }
+    // 每个key单独计算抖动，过期时间不应该全部相同
+    expires := make(map[time.Time]struct{})
+    b.mu.Lock()
+    for _, key := range keys {
+        expires[b.items[getCacheKey(key)].Value.(*memoryEntry).expireAt.Truncate(time.Second)] = struct{}{}
+    }
+    b.mu.Unlock()
+    if len(expires) < 2 {
+        t.Fatalf("expire time of batch keys should be jittered, got %d distinct values", len(expires))
+    }
+}
+
+// countingBackend 统计批量写入次数的Backend
+type countingBackend struct {
+    *MemoryBackend
+    writes int
+}
+
+func (c *countingBackend) MSet(items map[string][]byte, expire int64) error {
+    c.writes++
+    return c.MemoryBackend.MSet(items, expire)
+}
+
+func (c *countingBackend) MSetEach(items map[string][]byte, expires map[string]int64) error {
+    c.writes++
+    return c.MemoryBackend.MSetEach(items, expires)
+}
+
+func TestBCallManySingleWrite(t *testing.T) {
+    lc := NewLocalCache(nil, nil)
+    SetLocalCache(lc)
+    defer func() {
+        SetLocalCache(nil)
+        lc.Close()
+    }()
+
+    b := &countingBackend{MemoryBackend: NewMemoryBackend(0)}
+    bf := func(keys []string) (map[string]interface{}, error) {
+        return map[string]interface{}{"single:1": "a", "single:2": "b"}, nil
+    }
+    // 本地缓存中的旧数据在批量写入后被清除
+    lc.set(getCacheKey("single:1"), []byte(`"old"`))
+    var ret map[string]string
+    err := BCallMany(&ret, b, []string{"single:1", "single:2", "single:3"}, 60, bf,
+        WithExpireJitter(0.5), WithNotFoundTTL(10))
+    if err != nil || len(ret) != 2 {
+        t.Fatalf("unexpected result: %v, %v", ret, err)
+    }
+    if b.writes != 1 {
+        t.Fatalf("expect 1 batch write, got %d", b.writes)
+    }
+    if _, ok := lc.get(getCacheKey("single:1")); ok {
+        t.Fatal("local cache should be invalidated")
+    }
+    if v, _ := b.Get(getCacheKey("single:3")); !isNotFound(v) {
+        t.Fatalf("not found marker should be stored, got %q", v)
+    }
+
+    // 不支持的选项
+    for _, opt := range []CallOption{WithSingleflight(), WithStaleWhileRevalidate(10), WithLocalCache()} {
+        if err = BCallMany(&ret, b, []string{"single:4"}, 60, bf, opt); err != ErrBatchOptionUnsupported {
+            t.Fatalf("expect ErrBatchOptionUnsupported, got %v", err)
+        }
+    }
+}
@@ -0,0 +1,124 @@
+package cachex
+
+import (
+    "time"
+
+    "github.com/whencome/goutil/timeutil"
+)
+
+const (
+    activeKeyPrefix  = "active:" // 每日活跃位图key的前缀
+    activeTempExpire = 60        // 位运算临时结果的过期时间，单位：秒，避免删除失败时残留
+)
+
+// DailyActive 基于位图的每日活跃统计，每天一个位图，以用户ID作为偏移量
+// 日期按timeutil.GetLocation()的自然天划分
+type DailyActive struct {
+    b      Backend
+    name   string
+    expire int64
+}
+
+// NewDailyActive 创建每日活跃统计，name用于区分不同的统计对象（如login、order），会自动添加缓存前缀
+// expire - 每天位图的过期时间，单位：秒，小于等于0表示永不过期
+func NewDailyActive(b Backend, name string, expire int64) *DailyActive {
+    return &DailyActive{
+        b:      b,
+        name:   name,
+        expire: expire,
+    }
+}
+
+// Key 返回t所在日期的位图key
+func (d *DailyActive) Key(t time.Time) string {
+    return d.dateKey(dayBegin(t).Format("2006-01-02"))
+}
+
+// dateKey 返回日期（格式为2006-01-02）对应的位图key
+func (d *DailyActive) dateKey(date string) string {
+    return getCacheKey(activeKeyPrefix + d.name + ":" + date)
+}
+
+// dayBegin 返回t所在日期的零点
+func dayBegin(t time.Time) time.Time {
+    return timeutil.GetDateBeginTime(t.In(timeutil.GetLocation()))
+}
+
+// bitmap 检查Backend是否支持批量位操作
+func (d *DailyActive) bitmap() (BitmapBackend, error) {
+    bb, ok := d.b.(BitmapBackend)
+    if !ok {
+        return nil, ErrBitmapUnsupported
+    }
+    return bb, nil
+}
+
+// Mark 标记用户在t所在的日期活跃
+func (d *DailyActive) Mark(userID int64, t time.Time) error {
+    bb, err := d.bitmap()
+    if err != nil {
+        return err
+    }
+    if userID < 0 {
+        return ErrInvalidBitArgs
+    }
+    return bb.SetBits(d.Key(t), []int64{userID}, d.expire)
+}
+
+// IsActive 判断用户在t所在的日期是否活跃
+func (d *DailyActive) IsActive(userID int64, t time.Time) (bool, error) {
+    bit, err := d.b.GetBit(d.Key(t), userID)
+    return bit == 1, err
+}
+
+// Count 统计t所在日期的活跃用户数
+func (d *DailyActive) Count(t time.Time) (int64, error) {
+    bb, err := d.bitmap()
+    if err != nil {
+        return 0, err
+    }
+    return bb.BitCount(d.Key(t))
+}
+
+// CountAny 统计时间范围内至少活跃过一天的用户数，时间范围为左闭右开区间，如timeutil.MonthTimeRange的返回值
+func (d *DailyActive) CountAny(tr timeutil.TimeRange) (int64, error) {
+    return d.countRange(BitOpOr, tr)
+}
+
+// CountAll 统计时间范围内每天都活跃的用户数，时间范围为左闭右开区间
+func (d *DailyActive) CountAll(tr timeutil.TimeRange) (int64, error) {
+    return d.countRange(BitOpAnd, tr)
+}
+
+// dayKeys 返回时间范围内每一天的位图key
+func (d *DailyActive) dayKeys(tr timeutil.TimeRange) []string {
+    // 从开始时间所在日期的零点开始按天遍历
+    days := timeutil.TimeRange{StartTime: dayBegin(tr.StartTime), EndTime: tr.EndTime}
+    dates := days.Dates()
+    keys := make([]string, len(dates))
+    for i, date := range dates {
+        keys[i] = d.dateKey(date)
+    }
+    return keys
+}
+
+// countRange 对时间范围内每天的位图进行位运算，统计结果中值为1的位的数量
+func (d *DailyActive) countRange(op string, tr timeutil.TimeRange) (int64, error) {
+    bb, err := d.bitmap()
+    if err != nil {
+        return 0, err
+    }
+    keys := d.dayKeys(tr)
+    if len(keys) == 0 {
+        return 0, nil
+    }
+    if len(keys) == 1 {
+        return bb.BitCount(keys[0])
+    }
+    destKey := getCacheKey(activeKeyPrefix + d.name + ":tmp:" + randomToken())
+    if err = bb.BitOp(op, destKey, activeTempExpire, keys...); err != nil {
+        return 0, err
+    }
+    defer d.b.Del(destKey)
+    return bb.BitCount(destKey)
+}
@@ -0,0 +1,55 @@
+package cachex
+
+import (
+    "testing"
+    "time"
+
+    "github.com/whencome/goutil/timeutil"
+)
+
+func TestDailyActive(t *testing.T) {
+    b := NewMemoryBackend(0)
+    d := NewDailyActive(b, "login", 0)
+    loc := timeutil.GetLocation()
+    day1 := time.Date(2024, 3, 1, 8, 0, 0, 0, loc)
+    day2 := day1.AddDate(0, 0, 1)
+    day3 := day1.AddDate(0, 0, 2)
+    marks := map[time.Time][]int64{
+        day1: {1, 2, 3},
+        day2: {2, 3, 100},
+        day3: {3},
+    }
+    for day, users := range marks {
+        for _, uid := range users {
+            if err := d.Mark(uid, day); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    if ok, _ := d.IsActive(100, day2); !ok {
+        t.Fatal("user 100 should be active on day2")
+    }
+    if ok, _ := d.IsActive(100, day1); ok {
+        t.Fatal("user 100 should not be active on day1")
+    }
+    if n, _ := d.Count(day1); n != 3 {
+        t.Fatalf("expect 3 active users on day1, got %d", n)
+    }
+    // 不同时刻属于同一天
+    if d.Key(day1) != d.Key(time.Date(2024, 3, 1, 23, 59, 59, 0, loc)) {
+        t.Fatal("same day should share the same key")
+    }
+
+    tr := timeutil.TimeRange{StartTime: day1, EndTime: day3.Add(time.Hour)}
+    if n, err := d.CountAny(tr); err != nil || n != 4 {
+        t.Fatalf("expect 4 users active in range, got %d, %v", n, err)
+    }
+    if n, err := d.CountAll(tr); err != nil || n != 1 {
+        t.Fatalf("expect 1 user active every day, got %d, %v", n, err)
+    }
+    // 右边界为开区间
+    tr = timeutil.TimeRange{StartTime: day1, EndTime: timeutil.GetDateBeginTime(day2)}
+    if n, _ := d.CountAll(tr); n != 3 {
+        t.Fatalf("expect 3 users when range covers day1 only, got %d", n)
+    }
+}
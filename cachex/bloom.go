@@ -0,0 +1,142 @@
+package cachex
+
+import (
+    "encoding/binary"
+    "errors"
+    "hash/fnv"
+    "math"
+)
+
+const (
+    bloomKeyPrefix = "bloom:"  // 布隆过滤器key的前缀
+    bloomMaxBits   = 1 << 32   // redis位图的最大长度
+)
+
+// ErrBitmapUnsupported Backend未实现BitmapBackend接口
+var ErrBitmapUnsupported = errors.New("cachex: backend does not support bitmap")
+
+// BloomFilter 基于缓存位图的布隆过滤器，判断一个元素“一定不存在”或者“可能存在”
+// 常用于在调用Call之前拦截一定不存在的数据，防止缓存穿透
+type BloomFilter struct {
+    b      Backend
+    key    string
+    bits   uint64 // 位图的长度
+    hashes int    // 每个元素对应的位数
+}
+
+// NewBloomFilter 创建布隆过滤器，name会自动添加缓存前缀
+// capacity - 预计的元素数量，超出后误判率会上升
+// fpRate - 期望的误判率，取值范围(0, 1)，如0.01
+func NewBloomFilter(b Backend, name string, capacity int64, fpRate float64) *BloomFilter {
+    if capacity <= 0 {
+        capacity = 1
+    }
+    if fpRate <= 0 || fpRate >= 1 {
+        fpRate = 0.01
+    }
+    // m = -n*ln(p)/(ln2)^2, k = m/n*ln2
+    m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+    if m > bloomMaxBits {
+        m = bloomMaxBits
+    }
+    k := int(math.Round(m / float64(capacity) * math.Ln2))
+    if k < 1 {
+        k = 1
+    }
+    return &BloomFilter{
+        b:      b,
+        key:    getCacheKey(bloomKeyPrefix + name),
+        bits:   uint64(m),
+        hashes: k,
+    }
+}
+
+// Bits 返回位图的长度
+func (f *BloomFilter) Bits() uint64 {
+    return f.bits
+}
+
+// Hashes 返回每个元素对应的位数
+func (f *BloomFilter) Hashes() int {
+    return f.hashes
+}
+
+// offsets 计算元素对应的位，使用双重哈希：h1 + i*h2
+func (f *BloomFilter) offsets(item string) []int64 {
+    h := fnv.New128a()
+    _, _ = h.Write([]byte(item))
+    sum := h.Sum(nil)
+    h1 := binary.BigEndian.Uint64(sum[:8])
+    h2 := binary.BigEndian.Uint64(sum[8:]) | 1
+    offsets := make([]int64, f.hashes)
+    for i := range offsets {
+        offsets[i] = int64((h1 + uint64(i)*h2) % f.bits)
+    }
+    return offsets
+}
+
+// bitmap 检查Backend是否支持批量位操作
+func (f *BloomFilter) bitmap() (BitmapBackend, error) {
+    bb, ok := f.b.(BitmapBackend)
+    if !ok {
+        return nil, ErrBitmapUnsupported
+    }
+    return bb, nil
+}
+
+// Add 添加元素，每个元素的所有位在一次网络往返中设置
+func (f *BloomFilter) Add(items ...string) error {
+    bb, err := f.bitmap()
+    if err != nil {
+        return err
+    }
+    if len(items) == 0 {
+        return nil
+    }
+    offsets := make([]int64, 0, len(items)*f.hashes)
+    for _, item := range items {
+        offsets = append(offsets, f.offsets(item)...)
+    }
+    return bb.SetBits(f.key, offsets, 0)
+}
+
+// Exists 判断元素是否可能存在，返回false时元素一定不存在，返回true时有一定的概率误判
+func (f *BloomFilter) Exists(item string) (bool, error) {
+    bb, err := f.bitmap()
+    if err != nil {
+        return false, err
+    }
+    bits, err := bb.GetBits(f.key, f.offsets(item))
+    if err != nil {
+        return false, err
+    }
+    for _, bit := range bits {
+        if bit == 0 {
+            return false, nil
+        }
+    }
+    return true, nil
+}
+
+// Clear 清空布隆过滤器
+func (f *BloomFilter) Clear() error {
+    return f.b.Del(f.key)
+}
+
+// WithBloomFilter 缓存未命中时，先通过布隆过滤器判断item是否可能存在，一定不存在时直接返回ErrNotFound，不再调用BizFunc
+// 布隆过滤器出错时不影响业务，按可能存在处理
+func WithBloomFilter(f *BloomFilter, item string) CallOption {
+    return func(o *callOptions) {
+        o.bloom = f
+        o.bloomItem = item
+    }
+}
+
+// rejectedByBloom 判断是否被布隆过滤器拦截
+func (o *callOptions) rejectedByBloom() bool {
+    if o.bloom == nil {
+        return false
+    }
+    exists, err := o.bloom.Exists(o.bloomItem)
+    return err == nil && !exists
+}
@@ -0,0 +1,61 @@
+package cachex
+
+import (
+    "strconv"
+    "testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+    f := NewBloomFilter(NewMemoryBackend(0), "users", 1000, 0.01)
+    if f.Bits() == 0 || f.Hashes() != 7 {
+        t.Fatalf("unexpected filter size: bits %d, hashes %d", f.Bits(), f.Hashes())
+    }
+    items := make([]string, 0, 1000)
+    for i := 0; i < 1000; i++ {
+        items = append(items, "user:"+strconv.Itoa(i))
+    }
+    if err := f.Add(items...); err != nil {
+        t.Fatal(err)
+    }
+    for _, item := range items {
+        if ok, err := f.Exists(item); err != nil || !ok {
+            t.Fatalf("%s should exist: %v", item, err)
+        }
+    }
+    falsePositives := 0
+    for i := 1000; i < 11000; i++ {
+        if ok, _ := f.Exists("user:" + strconv.Itoa(i)); ok {
+            falsePositives++
+        }
+    }
+    if rate := float64(falsePositives) / 10000; rate > 0.03 {
+        t.Fatalf("false positive rate too high: %v", rate)
+    }
+    if err := f.Clear(); err != nil {
+        t.Fatal(err)
+    }
+    if ok, _ := f.Exists(items[0]); ok {
+        t.Fatal("filter should be cleared")
+    }
+}
+
+func TestBCallBloomFilter(t *testing.T) {
+    b := NewMemoryBackend(0)
+    f := NewBloomFilter(b, "items", 100, 0.01)
+    _ = f.Add("1")
+    calls := 0
+    bf := func() (interface{}, error) {
+        calls++
+        return "item", nil
+    }
+    var ret string
+    if err := BCall(&ret, b, "item:2", 60, bf, WithBloomFilter(f, "2")); err != ErrNotFound {
+        t.Fatalf("expect ErrNotFound, got %v", err)
+    }
+    if calls != 0 {
+        t.Fatal("business func should not be called for absent item")
+    }
+    if err := BCall(&ret, b, "item:1", 60, bf, WithBloomFilter(f, "1")); err != nil || ret != "item" {
+        t.Fatalf("unexpected result: %q, %v", ret, err)
+    }
+}
@@ -1,7 +1,7 @@
 package cachex
 
 import (
-    "fmt"
+    "context"
     "strings"
 
     "github.com/gomodule/redigo/redis"
@@ -45,8 +45,7 @@ func getCacheKey(k string) string {
 
 // Exists 判断指定key是否存在
 func Exists(rds redis.Conn, cacheKey string) (bool, error) {
-    cacheKey = getCacheKey(cacheKey)
-    return redis.Bool(rds.Do("EXISTS", cacheKey))
+    return BExists(NewRedisBackend(rds), cacheKey)
 }
 
 func PExists(provider Provider, cacheKey string) (bool, error) {
@@ -55,133 +54,187 @@ func PExists(provider Provider, cacheKey string) (bool, error) {
     return Exists(rds, cacheKey)
 }
 
+// BExists 判断指定key是否存在，使用Backend作为缓存后端，其它Bxxx的方法同理
+func BExists(b Backend, cacheKey string) (bool, error) {
+    cacheKey = getCacheKey(cacheKey)
+    return b.Exists(cacheKey)
+}
+
 // Call 带有缓存的调用，会将业务方法结果进行缓存
-func Call(ret interface{}, rds redis.Conn, cacheKey string, expire int64, bf BizFunc) error {
+func Call(ret interface{}, rds redis.Conn, cacheKey string, expire int64, bf BizFunc, opts ...CallOption) error {
+    return BCall(ret, NewRedisBackend(rds), cacheKey, expire, bf, opts...)
+}
+
+// PCall 使用provider作为redis连接提供方，并自行关闭连接，内部仍旧调用Call，其它Pxxx的方法同理
+func PCall(ret interface{}, provider Provider, cacheKey string, expire int64, bf BizFunc, opts ...CallOption) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return Call(ret, rds, cacheKey, expire, bf, withProviderRefresh(provider, opts)...)
+}
+
+// BCall 带有缓存的调用，会将业务方法结果进行缓存
+// opts - 可选参数，如WithSingleflight用于合并缓存未命中时的并发调用，WithNotFoundTTL用于缓存空结果，WithLocalCache用于启用本地缓存，
+// WithBloomFilter用于拦截一定不存在的数据
+// 命中空结果缓存时返回ErrNotFound
+func BCall(ret interface{}, b Backend, cacheKey string, expire int64, bf BizFunc, opts ...CallOption) error {
+    o := newCallOptions(opts)
+    if err := checkTags(b, o.tags); err != nil {
+        return err
+    }
     cacheKey = getCacheKey(cacheKey)
+    // get data from local cache
+    lc := o.getLocalCache()
+    if lc != nil {
+        if localData, ok := lc.get(cacheKey); ok {
+            if isNotFound(localData) {
+                emitHit(o.ctx, cacheKey, SourceLocal)
+                return ErrNotFound
+            }
+            payload, _ := unwrapEntry(localData)
+            if decodeValue(payload, ret) == nil {
+                emitHit(o.ctx, cacheKey, SourceLocal)
+                return nil
+            }
+        }
+    }
+
     // get data from cache
-    cacheData, err := redis.Bytes(rds.Do("GET", cacheKey))
+    cacheData, err := b.Get(cacheKey)
     if err == nil {
-        err = jsonkit.Unmarshal(cacheData, ret)
+        if isNotFound(cacheData) {
+            emitHit(o.ctx, cacheKey, SourceBackend)
+            if lc != nil {
+                lc.set(cacheKey, cacheData)
+            }
+            return ErrNotFound
+        }
+        payload, meta := unwrapEntry(cacheData)
+        err = decodeValue(payload, ret)
         if err == nil {
-            return nil
+            emitHit(o.ctx, cacheKey, SourceBackend)
+            // 检查是否需要刷新，同步刷新成功时使用新数据
+            if meta != nil {
+                if freshData := revalidate(b, cacheKey, expire, bf, o, meta); freshData != nil {
+                    cacheData = freshData
+                    if ret != nil && !o.isLoaded() {
+                        err = decodeValue(freshData, ret)
+                    }
+                }
+            }
+            if lc != nil && err == nil {
+                lc.set(cacheKey, cacheData)
+            }
+            return err
         }
     }
 
     // get data by call business func
-    data, err := bf()
+    o.emit(EventMiss, cacheKey, 0, nil)
+    if o.rejectedByBloom() {
+        return ErrNotFound
+    }
+    bytesData, err := loadData(b, cacheKey, expire, bf, o)
+    if err == ErrNotFound && lc != nil && o.notFoundExpire > 0 {
+        lc.set(cacheKey, notFoundMarker)
+    }
     if err != nil {
         return err
     }
-    if goutil.IsNil(data) {
+    if bytesData == nil {
         return nil
     }
-    bytesData, err := jsonkit.Marshal(data)
-    if err != nil {
-        return err
+    if lc != nil {
+        lc.set(cacheKey, bytesData)
     }
-    // 赋值
-    if ret != nil {
-        err = jsonkit.Unmarshal(bytesData, ret)
-        if err != nil {
-            return err
-        }
+    // 等待其它进程加载时可能读取到的是空结果
+    if isNotFound(bytesData) {
+        return ErrNotFound
     }
-
-    // 缓存数据(暂时忽略错误)
-    if expire > 0 {
-        _, _ = rds.Do("SETEX", cacheKey, expire, string(bytesData))
-    } else {
-        _, _ = rds.Do("SETEX", cacheKey, string(bytesData))
+    // 赋值，业务方法的结果已经直接交给调用方时不需要解码
+    if ret != nil && !o.isLoaded() {
+        bytesData, _ = unwrapEntry(bytesData)
+        return decodeValue(bytesData, ret)
     }
     return nil
 }
 
-// PCall 使用provider作为redis连接提供方，并自行关闭连接，内部仍旧调用Call，其它Pxxx的方法同理
-func PCall(ret interface{}, provider Provider, cacheKey string, expire int64, bf BizFunc) error {
-    rds := provider.Redis()
-    defer rds.Close()
-    return Call(ret, rds, cacheKey, expire, bf)
-}
-
 // ResetCall 重置调用，会忽略之前的缓存（先清除之前的缓存），然后在调用业务接口并缓存结果
-func ResetCall(ret interface{}, rds redis.Conn, cacheKey string, expire int64, bf BizFunc) error {
-    Remove(rds, cacheKey)
-    return Call(ret, rds, cacheKey, expire, bf)
+func ResetCall(ret interface{}, rds redis.Conn, cacheKey string, expire int64, bf BizFunc, opts ...CallOption) error {
+    return BResetCall(ret, NewRedisBackend(rds), cacheKey, expire, bf, opts...)
 }
 
 // PResetCall 重置调用，会忽略之前的缓存（先清除之前的缓存），然后在调用业务接口并缓存结果
-func PResetCall(ret interface{}, provider Provider, cacheKey string, expire int64, bf BizFunc) error {
+func PResetCall(ret interface{}, provider Provider, cacheKey string, expire int64, bf BizFunc, opts ...CallOption) error {
     rds := provider.Redis()
     defer rds.Close()
-    Remove(rds, cacheKey)
-    return Call(ret, rds, cacheKey, expire, bf)
+    return ResetCall(ret, rds, cacheKey, expire, bf, withProviderRefresh(provider, opts)...)
+}
+
+// BResetCall 重置调用，会忽略之前的缓存（先清除之前的缓存），然后在调用业务接口并缓存结果
+func BResetCall(ret interface{}, b Backend, cacheKey string, expire int64, bf BizFunc, opts ...CallOption) error {
+    BRemove(b, cacheKey)
+    return BCall(ret, b, cacheKey, expire, bf, opts...)
 }
 
-// LockCall 带有锁的调用，当存在并发调用可能时，先寻求获得cacheKey对应的锁，如果获取失败，则无法执行
+// LockCall 带有锁的调用，当存在并发调用可能时，先寻求获得cacheKey对应的锁，如果获取失败，则返回ErrLockNotObtained
 // autoUnlock - 标记是否自动释放锁（仅限请求成功时），如果不自动释放，则需要等待锁自动过期释放，此参数可以用于防止重复提交等场景
 func LockCall(ret interface{}, rds redis.Conn, cacheKey string, expire int64, autoUnlock bool, bf BizFunc) error {
-    cacheKey = getCacheKey(cacheKey)
-    // 1. 先检查锁是否存在，如果不存在再尝试获取锁
-    // 1.1 检查锁是否存在
-    isExists, err := redis.Bool(rds.Do("EXISTS", cacheKey))
-    if err != nil {
-        return err
-    }
-    if isExists {
-        return fmt.Errorf("你的手速有点快，喝口水再试试吧~")
+    return BLockCall(ret, NewRedisBackend(rds), cacheKey, expire, autoUnlock, bf)
+}
+
+// PLockCall 带有锁的调用
+func PLockCall(ret interface{}, provider Provider, cacheKey string, expire int64, autoUnlock bool, bf BizFunc) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return LockCall(ret, rds, cacheKey, expire, autoUnlock, bf)
+}
+
+// BLockCall 带有锁的调用
+// 锁已经被持有时返回ErrLockNotObtained，expire小于等于0时返回ErrInvalidLockTTL
+// Backend未实现LockBackend时使用SetNX获取锁，释放锁时先比较再删除（非原子操作）
+func BLockCall(ret interface{}, b Backend, cacheKey string, expire int64, autoUnlock bool, bf BizFunc) error {
+    if expire <= 0 {
+        return ErrInvalidLockTTL
     }
-    // 1.2 尝试获取锁
-    lockRs, err := rds.Do("SET", cacheKey, "1", "EX", expire, "NX")
+    // 1. 尝试获取锁
+    cacheKey = getCacheKey(cacheKey)
+    token := randomToken()
+    locked, err := acquireToken(b, cacheKey, token, expire)
     if err != nil {
         return err
     }
-    if lockRs == nil {
-        // 抢占失败
-        return fmt.Errorf("请求失败，请稍后再试")
+    if !locked {
+        emit(EventLockContention, cacheKey, 0, nil)
+        return ErrLockNotObtained
     }
 
     // 2. 执行业务调用
+    // 如果是失败不做处理，等待自行过期释放
     resp, err := bf()
     if err != nil {
         return err
     }
+    // 3. 业务结束,释放锁
+    if autoUnlock {
+        defer releaseToken(b, cacheKey, token)
+    }
     if goutil.IsNil(resp) {
-        ret = nil
         return nil
     }
     data, err := jsonkit.Marshal(resp)
     if err != nil {
         return err
     }
-    // 赋值
+    // 4. 赋值并返回结果
     if ret != nil {
-        err = jsonkit.Unmarshal(data, ret)
-        if err != nil {
-            return err
-        }
+        return jsonkit.Unmarshal(data, ret)
     }
-
-    // 3. 业务结束,释放锁
-    // 如果是失败不做处理，等待自行过期释放
-    if autoUnlock {
-        _, _ = rds.Do("DEL", cacheKey)
-    }
-
-    // 4. 返回结果
     return nil
 }
 
-// PLockCall 带有锁的调用
-func PLockCall(ret interface{}, provider Provider, cacheKey string, expire int64, autoUnlock bool, bf BizFunc) error {
-    rds := provider.Redis()
-    defer rds.Close()
-    return LockCall(ret, rds, cacheKey, expire, autoUnlock, bf)
-}
-
 // Remove 移除cacheKey对应的缓存
 func Remove(rds redis.Conn, cacheKey string) {
-    cacheKey = getCacheKey(cacheKey)
-    _, _ = rds.Do("DEL", cacheKey)
+    BRemove(NewRedisBackend(rds), cacheKey)
 }
 
 // PRemove 移除cacheKey对应的缓存
@@ -191,14 +244,16 @@ func PRemove(provider Provider, cacheKey string) {
     Remove(rds, cacheKey)
 }
 
+// BRemove 移除cacheKey对应的缓存
+func BRemove(b Backend, cacheKey string) {
+    cacheKey = getCacheKey(cacheKey)
+    _ = b.Del(cacheKey)
+    invalidateLocal(cacheKey)
+}
+
 // RemoveBatch 批量数据清除
 func RemoveBatch(rds redis.Conn, cacheKeys []string) {
-    if len(cacheKeys) == 0 {
-        return
-    }
-    for _, cacheKey := range cacheKeys {
-        Remove(rds, cacheKey)
-    }
+    BRemoveBatch(NewRedisBackend(rds), cacheKeys)
 }
 
 // PRemoveBatch 批量数据清除
@@ -208,117 +263,206 @@ func PRemoveBatch(provider Provider, cacheKeys []string) {
     RemoveBatch(rds, cacheKeys)
 }
 
+// BRemoveBatch 批量数据清除，只需要一次网络往返
+func BRemoveBatch(b Backend, cacheKeys []string) {
+    if len(cacheKeys) == 0 {
+        return
+    }
+    fullKeys := make([]string, len(cacheKeys))
+    for i, cacheKey := range cacheKeys {
+        fullKeys[i] = getCacheKey(cacheKey)
+    }
+    _ = b.Del(fullKeys...)
+    invalidateLocal(fullKeys...)
+}
+
 // Store 直接缓存结果
-func Store(rds redis.Conn, cacheKey string, expire int64, data interface{}) error {
+func Store(rds redis.Conn, cacheKey string, expire int64, data interface{}, opts ...CallOption) error {
+    return BStore(NewRedisBackend(rds), cacheKey, expire, data, opts...)
+}
+
+// StoreMany 缓存多个值
+func StoreMany(rds redis.Conn, expire int64, data map[string]interface{}, opts ...CallOption) error {
+    return BStoreMany(NewRedisBackend(rds), expire, data, opts...)
+}
+
+// PStore 直接缓存结果
+func PStore(provider Provider, cacheKey string, expire int64, data interface{}, opts ...CallOption) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return Store(rds, cacheKey, expire, data, opts...)
+}
+
+// PStoreMany 缓存多个值
+func PStoreMany(provider Provider, expire int64, data map[string]interface{}, opts ...CallOption) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return StoreMany(rds, expire, data, opts...)
+}
+
+// BStore 直接缓存结果，可以通过WithCodec指定编码格式，WithTags关联标签
+// Backend不支持标签时不写入缓存，直接返回ErrTagUnsupported；关联标签失败时删除写入的缓存并返回错误
+func BStore(b Backend, cacheKey string, expire int64, data interface{}, opts ...CallOption) error {
+    o := newCallOptions(opts)
+    if err := checkTags(b, o.tags); err != nil {
+        return err
+    }
     cacheKey = getCacheKey(cacheKey)
-    bytesData, err := jsonkit.Marshal(data)
+    bytesData, err := encodeValue(o.codec, data)
     if err != nil {
         return err
     }
-    if expire > 0 {
-        _, err = rds.Do("SETEX", cacheKey, expire, string(bytesData))
-    } else {
-        _, err = rds.Do("SET", cacheKey, string(bytesData))
+    if err = b.Set(cacheKey, bytesData, expire); err != nil {
+        o.emit(EventStoreError, cacheKey, 0, err)
+        return err
     }
-    return err
+    invalidateLocal(cacheKey)
+    return addTags(b, o.tags, expire, cacheKey)
 }
 
-// StoreMany 缓存多个值
-func StoreMany(rds redis.Conn, expire int64, data map[string]interface{}) error {
+// BStoreMany 缓存多个值，Backend支持批量操作时只需要一次网络往返
+func BStoreMany(b Backend, expire int64, data map[string]interface{}, opts ...CallOption) error {
     if len(data) == 0 {
         return nil
     }
+    o := newCallOptions(opts)
+    if err := checkTags(b, o.tags); err != nil {
+        return err
+    }
+    items := make(map[string][]byte, len(data))
     for cacheKey, cacheData := range data {
-        err := Store(rds, cacheKey, expire, cacheData)
+        bytesData, err := encodeValue(o.codec, cacheData)
         if err != nil {
             return err
         }
+        items[getCacheKey(cacheKey)] = bytesData
     }
-    return nil
+    if err := mset(b, items, expire); err != nil {
+        for key := range items {
+            o.emit(EventStoreError, key, 0, err)
+        }
+        return err
+    }
+    fullKeys := make([]string, 0, len(items))
+    for key := range items {
+        fullKeys = append(fullKeys, key)
+    }
+    invalidateLocal(fullKeys...)
+    return addTags(b, o.tags, expire, fullKeys...)
 }
 
-// PStore 直接缓存结果
-func PStore(provider Provider, cacheKey string, expire int64, data interface{}) error {
-    rds := provider.Redis()
-    defer rds.Close()
-    return Store(rds, cacheKey, expire, data)
+// Fetch 从缓存中取值
+func Fetch(ret interface{}, rds redis.Conn, cacheKey string) error {
+    return BFetch(ret, NewRedisBackend(rds), cacheKey)
 }
 
-// PStoreMany 缓存多个值
-func PStoreMany(provider Provider, expire int64, data map[string]interface{}) error {
+// PFetch 从缓存中取值
+func PFetch(ret interface{}, provider Provider, cacheKey string) error {
     rds := provider.Redis()
     defer rds.Close()
-    return StoreMany(rds, expire, data)
+    return Fetch(ret, rds, cacheKey)
 }
 
-// Fetch 从缓存中取值
-func Fetch(ret interface{}, rds redis.Conn, cacheKey string) error {
-    cacheKey = getCacheKey(cacheKey)
-    bytesData, err := redis.Bytes(rds.Do("GET", cacheKey))
+// BFetch 从缓存中取值，缓存不存在时不做任何处理，缓存的是空结果时返回ErrNotFound
+// 会根据数据中的编码格式标记自动选择解码方式
+func BFetch(ret interface{}, b Backend, cacheKey string) error {
+    return fetch(nil, ret, b, cacheKey)
+}
+
+// fetch 从缓存中取值，ctx会随事件传递给观察者
+func fetch(ctx context.Context, ret interface{}, b Backend, cacheKey string) error {
+    bytesData, err := fetchData(ctx, b, getCacheKey(cacheKey))
     if err != nil {
-        if err == redis.ErrNil {
+        if err == ErrNil {
             return nil
         }
         return err
     }
-    return jsonkit.Unmarshal(bytesData, ret)
+    if isNotFound(bytesData) {
+        return ErrNotFound
+    }
+    bytesData, _ = unwrapEntry(bytesData)
+    return decodeValue(bytesData, ret)
 }
 
-// PFetch 从缓存中取值
-func PFetch(ret interface{}, provider Provider, cacheKey string) error {
-    rds := provider.Redis()
-    defer rds.Close()
-    return Fetch(ret, rds, cacheKey)
+// fetchData 读取完整key对应的缓存数据，并通知观察者命中或者未命中，缓存不存在时返回ErrNil
+func fetchData(ctx context.Context, b Backend, key string) ([]byte, error) {
+    data, err := b.Get(key)
+    if err == ErrNil {
+        emitContext(ctx, EventMiss, key, 0, nil)
+        return nil, err
+    }
+    if err != nil {
+        return nil, err
+    }
+    emitHit(ctx, key, SourceBackend)
+    return data, nil
 }
 
 // Incr 对指定的key的数值加1
 func Incr(rds redis.Conn, cacheKey string) (int64, error) {
-    cacheKey = getCacheKey(cacheKey)
-    return redis.Int64(rds.Do("INCR", cacheKey))
+    return BIncr(NewRedisBackend(rds), cacheKey)
 }
 
 func PIncr(provider Provider, cacheKey string) (int64, error) {
+    rds := provider.Redis()
+    defer rds.Close()
+    return Incr(rds, cacheKey)
+}
+
+// BIncr 对指定的key的数值加1
+func BIncr(b Backend, cacheKey string) (int64, error) {
     cacheKey = getCacheKey(cacheKey)
-    return redis.Int64(provider.Redis().Do("INCR", cacheKey))
+    return b.IncrBy(cacheKey, 1)
 }
 
 // Decr 对指定的key的数值减1
 func Decr(rds redis.Conn, cacheKey string) (int64, error) {
-    cacheKey = getCacheKey(cacheKey)
-    return redis.Int64(rds.Do("INCR", cacheKey))
+    return BDecr(NewRedisBackend(rds), cacheKey)
 }
 
 func PDecr(provider Provider, cacheKey string) (int64, error) {
+    rds := provider.Redis()
+    defer rds.Close()
+    return Decr(rds, cacheKey)
+}
+
+// BDecr 对指定的key的数值减1
+func BDecr(b Backend, cacheKey string) (int64, error) {
     cacheKey = getCacheKey(cacheKey)
-    return redis.Int64(provider.Redis().Do("INCR", cacheKey))
+    return b.IncrBy(cacheKey, -1)
 }
 
 // SetBit 设置或清除指定偏移量上的位(bit)。位的设置或清除取决于 value，可以是 0 或者是 1 。
 // 当expire为大于0的时候，表示需要定时过期
 func SetBit(rds redis.Conn, cacheKey string, expire int64, offset int64, v int) error {
+    return BSetBit(NewRedisBackend(rds), cacheKey, expire, offset, v)
+}
+
+func PSetBit(provider Provider, cacheKey string, expire int64, offset int64, v int) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return SetBit(rds, cacheKey, expire, offset, v)
+}
+
+// BSetBit 设置或清除指定偏移量上的位(bit)
+func BSetBit(b Backend, cacheKey string, expire int64, offset int64, v int) error {
     cacheKey = getCacheKey(cacheKey)
-    _, err := rds.Do("SETBIT", cacheKey, offset, v)
+    _, err := b.SetBit(cacheKey, offset, v)
     if err != nil {
         return err
     }
     // 如果指定了过期时间，则需要进行设置
     // 过期时间单位为秒
     if expire > 0 {
-        _, _ = rds.Do("EXPIRE", cacheKey, expire)
+        _ = b.Expire(cacheKey, expire)
     }
     return err
 }
 
-func PSetBit(provider Provider, cacheKey string, expire int64, offset int64, v int) error {
-    rds := provider.Redis()
-    defer rds.Close()
-    return SetBit(rds, cacheKey, expire, offset, v)
-}
-
 // GetBit 获取指定位的值
 func GetBit(rds redis.Conn, cacheKey string, offset int64) (int, error) {
-    cacheKey = getCacheKey(cacheKey)
-    return redis.Int(rds.Do("GETBIT", cacheKey, offset))
+    return BGetBit(NewRedisBackend(rds), cacheKey, offset)
 }
 
 func PGetBit(provider Provider, cacheKey string, offset int64) (int, error) {
@@ -326,3 +470,9 @@ func PGetBit(provider Provider, cacheKey string, offset int64) (int, error) {
     defer rds.Close()
     return GetBit(rds, cacheKey, offset)
 }
+
+// BGetBit 获取指定位的值
+func BGetBit(b Backend, cacheKey string, offset int64) (int, error) {
+    cacheKey = getCacheKey(cacheKey)
+    return b.GetBit(cacheKey, offset)
+}
@@ -0,0 +1,224 @@
+package cachex
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/gob"
+    "errors"
+    "io"
+    "sync"
+
+    "github.com/whencome/goutil/jsonkit"
+)
+
+// codecMagic 带有编码格式标记的数据的首字节，合法的json数据不会以此字节开头
+// 格式为：codecMagic + 编码格式ID + 编码后的数据，不带标记的数据均按json解析，以兼容旧版本写入的数据
+const codecMagic byte = 0x01
+
+// 内置编码格式的ID
+const (
+    CodecIDJSON     byte = 0   // json，为兼容旧数据，json编码的数据不添加标记
+    CodecIDGob      byte = 'g' // encoding/gob
+    CodecIDMsgpack  byte = 'm' // msgpack，需要使用msgpack构建标签编译
+    CodecIDCompress byte = 'z' // 压缩，内部再嵌套其它编码格式
+)
+
+var (
+    // ErrUnknownCodec 缓存数据的编码格式未注册
+    ErrUnknownCodec = errors.New("cachex: unknown codec")
+)
+
+// Codec 定义缓存数据的序列化接口
+type Codec interface {
+    // ID 编码格式标识，会写入数据头部用于识别编码格式，0保留给json
+    ID() byte
+    // Marshal 序列化数据
+    Marshal(v interface{}) ([]byte, error)
+    // Unmarshal 反序列化数据
+    Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+    // JSONCodec 使用jsonkit进行json编码，为默认的编码格式
+    JSONCodec Codec = jsonCodec{}
+    // GobCodec 使用encoding/gob编码，能够保留[]byte、time.Time等类型的完整信息
+    GobCodec Codec = gobCodec{}
+)
+
+var (
+    codecMu      sync.RWMutex
+    codecs       = map[byte]Codec{}
+    defaultCodec = JSONCodec
+)
+
+func init() {
+    RegisterCodec(JSONCodec)
+    RegisterCodec(GobCodec)
+    // 解码时不依赖压缩编码的参数，注册一个默认实例即可读取压缩数据
+    RegisterCodec(NewCompressCodec(nil, 0))
+}
+
+// RegisterCodec 注册编码格式，读取缓存时根据数据头部的标记找到对应的编码格式进行解码
+func RegisterCodec(c Codec) {
+    codecMu.Lock()
+    defer codecMu.Unlock()
+    codecs[c.ID()] = c
+}
+
+// SetDefaultCodec 设置全局默认的编码格式，会自动注册此编码格式
+func SetDefaultCodec(c Codec) {
+    RegisterCodec(c)
+    codecMu.Lock()
+    defer codecMu.Unlock()
+    defaultCodec = c
+}
+
+// getCodec 获取编码格式，c为nil时返回默认编码格式
+func getCodec(c Codec) Codec {
+    if c != nil {
+        return c
+    }
+    codecMu.RLock()
+    defer codecMu.RUnlock()
+    return defaultCodec
+}
+
+// lookupCodec 根据ID查找已注册的编码格式
+func lookupCodec(id byte) Codec {
+    codecMu.RLock()
+    defer codecMu.RUnlock()
+    return codecs[id]
+}
+
+// encodeValue 使用指定的编码格式序列化数据，并添加编码格式标记
+func encodeValue(c Codec, v interface{}) ([]byte, error) {
+    c = getCodec(c)
+    data, err := c.Marshal(v)
+    if err != nil {
+        return nil, err
+    }
+    if c.ID() == CodecIDJSON {
+        return data, nil
+    }
+    buf := make([]byte, 0, len(data)+2)
+    buf = append(buf, codecMagic, c.ID())
+    return append(buf, data...), nil
+}
+
+// decodeValue 根据数据头部的编码格式标记反序列化数据，不带标记的数据按json解析
+func decodeValue(data []byte, v interface{}) error {
+    if len(data) < 2 || data[0] != codecMagic {
+        return JSONCodec.Unmarshal(data, v)
+    }
+    c := lookupCodec(data[1])
+    if c == nil {
+        return ErrUnknownCodec
+    }
+    return c.Unmarshal(data[2:], v)
+}
+
+// jsonCodec json编码
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte {
+    return CodecIDJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+    return jsonkit.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+    return jsonkit.Unmarshal(data, v)
+}
+
+// gobCodec gob编码
+type gobCodec struct{}
+
+func (gobCodec) ID() byte {
+    return CodecIDGob
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+    buf := bytes.Buffer{}
+    if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+    return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// 压缩编码中标识数据是否被压缩
+const (
+    compressFlagRaw  byte = 0
+    compressFlagGzip byte = 1
+)
+
+// compressCodec 压缩编码，数据长度达到阈值时使用gzip压缩
+// 格式为：压缩标记 + 内部编码格式编码后的数据（带有内部编码格式的标记）
+type compressCodec struct {
+    inner     Codec
+    threshold int
+}
+
+// NewCompressCodec 创建一个压缩编码，使用inner编码数据，编码后的长度达到threshold字节时使用gzip压缩
+// inner为nil时使用json
+func NewCompressCodec(inner Codec, threshold int) Codec {
+    if inner == nil {
+        inner = JSONCodec
+    }
+    return &compressCodec{
+        inner:     inner,
+        threshold: threshold,
+    }
+}
+
+func (c *compressCodec) ID() byte {
+    return CodecIDCompress
+}
+
+func (c *compressCodec) Marshal(v interface{}) ([]byte, error) {
+    data, err := encodeValue(c.inner, v)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < c.threshold {
+        return append([]byte{compressFlagRaw}, data...), nil
+    }
+    buf := bytes.Buffer{}
+    buf.WriteByte(compressFlagGzip)
+    zw := gzip.NewWriter(&buf)
+    if _, err = zw.Write(data); err != nil {
+        return nil, err
+    }
+    if err = zw.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (c *compressCodec) Unmarshal(data []byte, v interface{}) error {
+    if len(data) == 0 {
+        return ErrUnknownCodec
+    }
+    switch data[0] {
+    case compressFlagRaw:
+        return decodeValue(data[1:], v)
+    case compressFlagGzip:
+        zr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+        if err != nil {
+            return err
+        }
+        defer zr.Close()
+        raw, err := io.ReadAll(zr)
+        if err != nil {
+            return err
+        }
+        return decodeValue(raw, v)
+    default:
+        return ErrUnknownCodec
+    }
+}
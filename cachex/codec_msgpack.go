@@ -0,0 +1,29 @@
+//go:build msgpack
+
+package cachex
+
+import (
+    "github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackCodec 使用msgpack编码，体积比json更小，需要使用msgpack构建标签编译（go build -tags msgpack）
+var MsgpackCodec Codec = msgpackCodec{}
+
+func init() {
+    RegisterCodec(MsgpackCodec)
+}
+
+// msgpackCodec msgpack编码
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte {
+    return CodecIDMsgpack
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+    return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+    return msgpack.Unmarshal(data, v)
+}
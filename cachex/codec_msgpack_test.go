@@ -0,0 +1,30 @@
+//go:build msgpack
+
+package cachex
+
+import (
+    "bytes"
+    "testing"
+    "time"
+)
+
+func TestMsgpackCodec(t *testing.T) {
+    item := codecItem{
+        Name:    "msgpack",
+        Raw:     []byte{0, 1, 2, 255},
+        Created: time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC),
+    }
+    for _, c := range []Codec{MsgpackCodec, NewCompressCodec(MsgpackCodec, 16)} {
+        data, err := encodeValue(c, item)
+        if err != nil {
+            t.Fatalf("codec %c encode failed: %s", c.ID(), err)
+        }
+        var ret codecItem
+        if err = decodeValue(data, &ret); err != nil {
+            t.Fatalf("codec %c decode failed: %s", c.ID(), err)
+        }
+        if ret.Name != item.Name || !bytes.Equal(ret.Raw, item.Raw) || !ret.Created.Equal(item.Created) {
+            t.Fatalf("codec %c decode result not match: %+v", c.ID(), ret)
+        }
+    }
+}
@@ -0,0 +1,57 @@
+package cachex
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "time"
+)
+
+type codecItem struct {
+    Name    string
+    Raw     []byte
+    Created time.Time
+}
+
+func TestCodecs(t *testing.T) {
+    item := codecItem{
+        Name:    strings.Repeat("cachex", 100),
+        Raw:     []byte{0, 1, 2, 255},
+        Created: time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC),
+    }
+    codecs := []Codec{JSONCodec, GobCodec, NewCompressCodec(GobCodec, 128), NewCompressCodec(nil, 1<<20)}
+    for _, c := range codecs {
+        data, err := encodeValue(c, item)
+        if err != nil {
+            t.Fatalf("codec %c encode failed: %s", c.ID(), err)
+        }
+        var ret codecItem
+        if err = decodeValue(data, &ret); err != nil {
+            t.Fatalf("codec %c decode failed: %s", c.ID(), err)
+        }
+        if ret.Name != item.Name || !bytes.Equal(ret.Raw, item.Raw) || !ret.Created.Equal(item.Created) {
+            t.Fatalf("codec %c decode result not match: %+v", c.ID(), ret)
+        }
+    }
+}
+
+func TestBCallWithCodec(t *testing.T) {
+    b := NewMemoryBackend(0)
+    bf := func() (interface{}, error) {
+        return codecItem{Name: "gob"}, nil
+    }
+    var ret codecItem
+    if err := BCall(&ret, b, "codec:gob", 60, bf, WithCodec(GobCodec)); err != nil || ret.Name != "gob" {
+        t.Fatalf("unexpected result: %+v, %v", ret, err)
+    }
+    // 不指定编码格式的读取方也能正确解码
+    ret = codecItem{}
+    if err := BFetch(&ret, b, "codec:gob"); err != nil || ret.Name != "gob" {
+        t.Fatalf("unexpected fetch result: %+v, %v", ret, err)
+    }
+    // 旧版本写入的json数据仍然可以读取
+    _ = b.Set(getCacheKey("codec:json"), []byte(`{"Name":"json"}`), 0)
+    if err := BFetch(&ret, b, "codec:json"); err != nil || ret.Name != "json" {
+        t.Fatalf("unexpected fetch result: %+v, %v", ret, err)
+    }
+}
@@ -0,0 +1,256 @@
+package cachex
+
+import (
+    "context"
+    "time"
+
+    "github.com/gomodule/redigo/redis"
+)
+
+// BizFuncContext 定义接收context的业务方法，业务方法应当在ctx结束时尽快返回
+type BizFuncContext func(ctx context.Context) (interface{}, error)
+
+// detachedContext 保留父context中的值，但不会被取消，也没有截止时间，用于后台刷新
+type detachedContext struct {
+    parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+    return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+    return nil
+}
+
+func (detachedContext) Err() error {
+    return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+    return c.parent.Value(key)
+}
+
+// withCallContext 设置本次调用的context以及接收context的业务方法
+func withCallContext(ctx context.Context, bf BizFuncContext) CallOption {
+    return func(o *callOptions) {
+        o.ctx = ctx
+        o.bizCtx = bf
+    }
+}
+
+// contextOptions 在调用方传入的参数之后追加context参数，不修改调用方的切片
+func contextOptions(ctx context.Context, bf BizFuncContext, opts []CallOption) []CallOption {
+    ctxOpts := make([]CallOption, 0, len(opts)+1)
+    ctxOpts = append(ctxOpts, opts...)
+    return append(ctxOpts, withCallContext(ctx, bf))
+}
+
+// context 返回本次调用的context，未设置时返回context.Background()
+func (o *callOptions) context() context.Context {
+    if o.ctx == nil {
+        return context.Background()
+    }
+    return o.ctx
+}
+
+// detach 复制一份参数用于后台刷新，context中的值仍然可用，但不再受调用方取消或超时的影响
+func (o *callOptions) detach() *callOptions {
+    c := *o
+    if o.ctx != nil {
+        c.ctx = detachedContext{parent: o.ctx}
+    }
+    return &c
+}
+
+// invoke 调用业务方法，设置了context时优先调用BizFuncContext，并在ctx结束时立即返回ctx的错误
+func (o *callOptions) invoke(bf BizFunc) (interface{}, error) {
+    if o.bizCtx != nil {
+        ctx := o.context()
+        return invokeContext(ctx, func() (interface{}, error) {
+            return o.bizCtx(ctx)
+        })
+    }
+    if o.ctx != nil {
+        return invokeContext(o.ctx, bf)
+    }
+    return bf()
+}
+
+// invokeContext 在新的goroutine中执行fn，ctx结束时不再等待fn返回，fn的结果将被丢弃
+// fn发生的panic会在调用方重新抛出
+func invokeContext(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    type result struct {
+        data  interface{}
+        err   error
+        panic interface{}
+    }
+    ch := make(chan result, 1)
+    go func() {
+        var r result
+        defer func() {
+            if p := recover(); p != nil {
+                r.panic = p
+            }
+            ch <- r
+        }()
+        r.data, r.err = fn()
+    }()
+    select {
+    case r := <-ch:
+        if r.panic != nil {
+            panic(r.panic)
+        }
+        return r.data, r.err
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
+
+// contextError ctx已经结束时返回ctx的错误，代替执行过程中由于ctx结束产生的其它错误
+func contextError(ctx context.Context, err error) error {
+    if err == nil {
+        return nil
+    }
+    if ctxErr := ctx.Err(); ctxErr != nil {
+        return ctxErr
+    }
+    return err
+}
+
+// CallContext 带有缓存的调用，与Call相同，但使用ctx控制redis命令以及业务方法的超时和取消
+// ctx结束时立即返回ctx.Err()，此时rds可能已经被关闭，不能继续使用
+func CallContext(ctx context.Context, ret interface{}, rds redis.Conn, cacheKey string, expire int64, bf BizFuncContext, opts ...CallOption) error {
+    return BCallContext(ctx, ret, NewRedisBackend(rds), cacheKey, expire, bf, opts...)
+}
+
+// PCallContext 带有缓存的调用，使用ctx控制超时和取消
+func PCallContext(ctx context.Context, ret interface{}, provider Provider, cacheKey string, expire int64, bf BizFuncContext, opts ...CallOption) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    rds := provider.Redis()
+    defer rds.Close()
+    return CallContext(ctx, ret, rds, cacheKey, expire, bf, withProviderRefresh(provider, opts)...)
+}
+
+// BCallContext 带有缓存的调用，使用ctx控制超时和取消
+// Backend实现了ContextBackend时，缓存操作同样受ctx控制；后台刷新不受ctx取消的影响
+func BCallContext(ctx context.Context, ret interface{}, b Backend, cacheKey string, expire int64, bf BizFuncContext, opts ...CallOption) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    legacy := func() (interface{}, error) {
+        return bf(ctx)
+    }
+    err := BCall(ret, backendWithContext(b, ctx), cacheKey, expire, legacy, contextOptions(ctx, bf, opts)...)
+    return contextError(ctx, err)
+}
+
+// LockCallContext 带有锁的调用，与LockCall相同，但使用ctx控制超时和取消
+func LockCallContext(ctx context.Context, ret interface{}, rds redis.Conn, cacheKey string, expire int64, autoUnlock bool, bf BizFuncContext) error {
+    return BLockCallContext(ctx, ret, NewRedisBackend(rds), cacheKey, expire, autoUnlock, bf)
+}
+
+// PLockCallContext 带有锁的调用，使用ctx控制超时和取消
+func PLockCallContext(ctx context.Context, ret interface{}, provider Provider, cacheKey string, expire int64, autoUnlock bool, bf BizFuncContext) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    rds := provider.Redis()
+    defer rds.Close()
+    return LockCallContext(ctx, ret, rds, cacheKey, expire, autoUnlock, bf)
+}
+
+// BLockCallContext 带有锁的调用，使用ctx控制超时和取消
+// 业务方法因ctx结束而返回时与失败相同，锁不会被释放，等待自行过期
+func BLockCallContext(ctx context.Context, ret interface{}, b Backend, cacheKey string, expire int64, autoUnlock bool, bf BizFuncContext) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    err := BLockCall(ret, backendWithContext(b, ctx), cacheKey, expire, autoUnlock, func() (interface{}, error) {
+        return invokeContext(ctx, func() (interface{}, error) {
+            return bf(ctx)
+        })
+    })
+    return contextError(ctx, err)
+}
+
+// FetchContext 从缓存中取值，使用ctx控制超时和取消
+func FetchContext(ctx context.Context, ret interface{}, rds redis.Conn, cacheKey string) error {
+    return BFetchContext(ctx, ret, NewRedisBackend(rds), cacheKey)
+}
+
+// PFetchContext 从缓存中取值，使用ctx控制超时和取消
+func PFetchContext(ctx context.Context, ret interface{}, provider Provider, cacheKey string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    rds := provider.Redis()
+    defer rds.Close()
+    return FetchContext(ctx, ret, rds, cacheKey)
+}
+
+// BFetchContext 从缓存中取值，使用ctx控制超时和取消
+func BFetchContext(ctx context.Context, ret interface{}, b Backend, cacheKey string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    err := fetch(ctx, ret, backendWithContext(b, ctx), cacheKey)
+    return contextError(ctx, err)
+}
+
+// StoreContext 直接缓存结果，使用ctx控制超时和取消
+func StoreContext(ctx context.Context, rds redis.Conn, cacheKey string, expire int64, data interface{}, opts ...CallOption) error {
+    return BStoreContext(ctx, NewRedisBackend(rds), cacheKey, expire, data, opts...)
+}
+
+// PStoreContext 直接缓存结果，使用ctx控制超时和取消
+func PStoreContext(ctx context.Context, provider Provider, cacheKey string, expire int64, data interface{}, opts ...CallOption) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    rds := provider.Redis()
+    defer rds.Close()
+    return StoreContext(ctx, rds, cacheKey, expire, data, opts...)
+}
+
+// BStoreContext 直接缓存结果，使用ctx控制超时和取消
+func BStoreContext(ctx context.Context, b Backend, cacheKey string, expire int64, data interface{}, opts ...CallOption) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    err := BStore(backendWithContext(b, ctx), cacheKey, expire, data, opts...)
+    return contextError(ctx, err)
+}
+
+// RemoveContext 移除cacheKey对应的缓存，使用ctx控制超时和取消
+func RemoveContext(ctx context.Context, rds redis.Conn, cacheKey string) error {
+    return BRemoveContext(ctx, NewRedisBackend(rds), cacheKey)
+}
+
+// PRemoveContext 移除cacheKey对应的缓存，使用ctx控制超时和取消
+func PRemoveContext(ctx context.Context, provider Provider, cacheKey string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    rds := provider.Redis()
+    defer rds.Close()
+    return RemoveContext(ctx, rds, cacheKey)
+}
+
+// BRemoveContext 移除cacheKey对应的缓存，使用ctx控制超时和取消，与BRemove不同，会返回删除时发生的错误
+func BRemoveContext(ctx context.Context, b Backend, cacheKey string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    cacheKey = getCacheKey(cacheKey)
+    if err := backendWithContext(b, ctx).Del(cacheKey); err != nil {
+        return contextError(ctx, err)
+    }
+    invalidateLocal(cacheKey)
+    return nil
+}
@@ -0,0 +1,115 @@
+package cachex
+
+import (
+    "context"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestBCallContextDeadline(t *testing.T) {
+    b := NewMemoryBackend(0)
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+    defer cancel()
+    bf := func(ctx context.Context) (interface{}, error) {
+        select {
+        case <-time.After(time.Second):
+            return "slow", nil
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+    start := time.Now()
+    var ret string
+    if err := BCallContext(ctx, &ret, b, "ctx:slow", 60, bf); err != context.DeadlineExceeded {
+        t.Fatalf("expect deadline exceeded, got %v", err)
+    }
+    if cost := time.Since(start); cost > time.Millisecond*500 {
+        t.Fatalf("call should return promptly, cost %v", cost)
+    }
+    if ok, _ := BExists(b, "ctx:slow"); ok {
+        t.Fatal("result should not be cached after deadline")
+    }
+
+    // 已经结束的ctx不会调用业务方法
+    calls := 0
+    err := BCallContext(ctx, &ret, b, "ctx:done", 60, func(ctx context.Context) (interface{}, error) {
+        calls++
+        return "v", nil
+    })
+    if err != context.DeadlineExceeded || calls != 0 {
+        t.Fatalf("expect deadline exceeded without calling, got %v, calls %d", err, calls)
+    }
+}
+
+func TestBCallContextValue(t *testing.T) {
+    type ctxKey struct{}
+    b := NewMemoryBackend(0)
+    ctx := context.WithValue(context.Background(), ctxKey{}, "tenant-7")
+    var ret string
+    err := BCallContext(ctx, &ret, b, "ctx:value", 60, func(ctx context.Context) (interface{}, error) {
+        return ctx.Value(ctxKey{}), nil
+    })
+    if err != nil || ret != "tenant-7" {
+        t.Fatalf("unexpected result: %q, %v", ret, err)
+    }
+    ret = ""
+    if err = BFetchContext(ctx, &ret, b, "ctx:value"); err != nil || ret != "tenant-7" {
+        t.Fatalf("unexpected fetch result: %q, %v", ret, err)
+    }
+    if err = BRemoveContext(ctx, b, "ctx:value"); err != nil {
+        t.Fatal(err)
+    }
+    if ok, _ := BExists(b, "ctx:value"); ok {
+        t.Fatal("cache should be removed")
+    }
+}
+
+func TestBCallContextSingleflightWaiter(t *testing.T) {
+    b := NewMemoryBackend(0)
+    release := make(chan struct{})
+    bf := func(ctx context.Context) (interface{}, error) {
+        <-release
+        return "v", nil
+    }
+    wg := sync.WaitGroup{}
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        var ret string
+        if err := BCallContext(context.Background(), &ret, b, "ctx:sf", 60, bf, WithSingleflight()); err != nil {
+            t.Error(err)
+        }
+    }()
+    time.Sleep(time.Millisecond * 20)
+
+    // 等待方的ctx结束时不再等待执行方
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+    defer cancel()
+    var ret string
+    if err := BCallContext(ctx, &ret, b, "ctx:sf", 60, bf, WithSingleflight()); err != context.DeadlineExceeded {
+        t.Fatalf("expect deadline exceeded, got %v", err)
+    }
+    close(release)
+    wg.Wait()
+}
+
+func TestBLockCallContext(t *testing.T) {
+    b := NewMemoryBackend(0)
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+    defer cancel()
+    var ret string
+    err := BLockCallContext(ctx, &ret, b, "ctx:lock", 10, true, func(ctx context.Context) (interface{}, error) {
+        <-ctx.Done()
+        return nil, ctx.Err()
+    })
+    if err != context.DeadlineExceeded {
+        t.Fatalf("expect deadline exceeded, got %v", err)
+    }
+    // 业务方法失败时锁不会被释放
+    if err = BLockCall(&ret, b, "ctx:lock", 10, true, func() (interface{}, error) {
+        return "v", nil
+    }); err != ErrLockNotObtained {
+        t.Fatalf("expect lock not obtained, got %v", err)
+    }
+}
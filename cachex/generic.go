@@ -0,0 +1,211 @@
+package cachex
+
+import (
+    "fmt"
+    "sync"
+)
+
+// Get 从缓存中获取类型为T的值，缓存不存在时返回ErrNil，缓存的是空结果时返回ErrNotFound
+func Get[T any](b Backend, cacheKey string) (T, error) {
+    var ret T
+    data, err := fetchData(nil, b, getCacheKey(cacheKey))
+    if err != nil {
+        return ret, err
+    }
+    if isNotFound(data) {
+        return ret, ErrNotFound
+    }
+    data, _ = unwrapEntry(data)
+    err = decodeValue(data, &ret)
+    return ret, err
+}
+
+// Set 缓存类型为T的值
+func Set[T any](b Backend, cacheKey string, expire int64, v T, opts ...CallOption) error {
+    return BStore(b, cacheKey, expire, v, opts...)
+}
+
+// GetOrLoad 带有缓存的调用，与BCall相同，但直接返回类型为T的值，不需要预先分配结果对象
+// 缓存未命中并由本次调用执行loader时，直接返回loader的结果，只在写入缓存时编码
+func GetOrLoad[T any](b Backend, cacheKey string, expire int64, loader func() (T, error), opts ...CallOption) (T, error) {
+    lv := &loadedValue[T]{}
+    bf := func() (interface{}, error) {
+        v, err := loader()
+        if err == nil {
+            lv.set(v)
+        }
+        return v, err
+    }
+    var ret T
+    // 复制参数列表，避免append修改调用方共享的opts
+    opts = append(opts[:len(opts):len(opts)], withLoaded(lv.loaded))
+    err := BCall(&ret, b, cacheKey, expire, bf, opts...)
+    if err != nil {
+        return ret, err
+    }
+    if v, ok := lv.get(); ok {
+        return v, nil
+    }
+    return ret, nil
+}
+
+// loadedValue 记录loader在本次调用中加载的值，后台刷新可能在其它goroutine中执行loader，需要加锁
+type loadedValue[T any] struct {
+    mu sync.Mutex
+    v  T
+    ok bool
+}
+
+func (l *loadedValue[T]) set(v T) {
+    l.mu.Lock()
+    l.v = v
+    l.ok = true
+    l.mu.Unlock()
+}
+
+func (l *loadedValue[T]) get() (T, bool) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.v, l.ok
+}
+
+func (l *loadedValue[T]) loaded() bool {
+    _, ok := l.get()
+    return ok
+}
+
+// withLoaded 设置判断业务方法的结果是否已经直接交给调用方的方法
+func withLoaded(fn func() bool) CallOption {
+    return func(o *callOptions) {
+        o.loaded = fn
+    }
+}
+
+// isLoaded 判断业务方法的结果是否已经直接交给调用方
+func (o *callOptions) isLoaded() bool {
+    return o.loaded != nil && o.loaded()
+}
+
+// KeyFormat 返回一个使用fmt.Sprintf(format, k)生成缓存key的方法，如：KeyFormat[int64]("user:%d")
+func KeyFormat[K any](format string) func(K) string {
+    return func(k K) string {
+        return fmt.Sprintf(format, k)
+    }
+}
+
+// Cache 类型化的缓存对象，绑定了Backend、缓存key的生成方法、过期时间以及可选参数，避免每次调用时重复传入
+type Cache[K comparable, V any] struct {
+    b       Backend
+    keyFunc func(K) string
+    expire  int64
+    opts    []CallOption
+}
+
+// NewCache 创建一个类型化的缓存对象
+// keyFunc - 根据业务主键生成缓存key的方法，可以使用KeyFormat
+// expire - 缓存过期时间，单位：秒
+// opts - 每次调用时使用的可选参数
+func NewCache[K comparable, V any](b Backend, keyFunc func(K) string, expire int64, opts ...CallOption) *Cache[K, V] {
+    return &Cache[K, V]{
+        b:       b,
+        keyFunc: keyFunc,
+        expire:  expire,
+        opts:    opts,
+    }
+}
+
+// Key 返回业务主键对应的缓存key（不含前缀）
+func (c *Cache[K, V]) Key(k K) string {
+    return c.keyFunc(k)
+}
+
+// Get 从缓存中获取值，缓存不存在时返回ErrNil，缓存的是空结果时返回ErrNotFound
+func (c *Cache[K, V]) Get(k K) (V, error) {
+    return Get[V](c.b, c.keyFunc(k))
+}
+
+// Set 缓存值
+func (c *Cache[K, V]) Set(k K, v V) error {
+    return Set[V](c.b, c.keyFunc(k), c.expire, v, c.opts...)
+}
+
+// GetOrLoad 从缓存中获取值，缓存不存在时调用loader加载并缓存
+func (c *Cache[K, V]) GetOrLoad(k K, loader func(K) (V, error)) (V, error) {
+    return GetOrLoad[V](c.b, c.keyFunc(k), c.expire, func() (V, error) {
+        return loader(k)
+    }, c.opts...)
+}
+
+// Delete 删除缓存
+func (c *Cache[K, V]) Delete(keys ...K) {
+    BRemoveBatch(c.b, c.cacheKeys(keys))
+}
+
+// GetMany 批量从缓存中获取值，返回缓存中存在的值以及不存在的业务主键
+func (c *Cache[K, V]) GetMany(keys []K) (map[K]V, []K, error) {
+    cacheKeys := c.cacheKeys(keys)
+    var values map[string]V
+    misses, err := BFetchMany(&values, c.b, cacheKeys)
+    if err != nil {
+        return nil, nil, err
+    }
+    ret := make(map[K]V, len(values))
+    for i, k := range keys {
+        if v, ok := values[cacheKeys[i]]; ok {
+            ret[k] = v
+        }
+    }
+    missKeys := make([]K, 0, len(misses))
+    missSet := make(map[string]struct{}, len(misses))
+    for _, m := range misses {
+        missSet[m] = struct{}{}
+    }
+    for i, k := range keys {
+        if _, ok := missSet[cacheKeys[i]]; ok {
+            missKeys = append(missKeys, k)
+        }
+    }
+    return ret, missKeys, nil
+}
+
+// GetOrLoadMany 批量获取值，仅对缓存中不存在的业务主键调用loader加载，并将结果写入缓存
+func (c *Cache[K, V]) GetOrLoadMany(keys []K, loader func([]K) (map[K]V, error)) (map[K]V, error) {
+    cacheKeys := c.cacheKeys(keys)
+    keyMap := make(map[string]K, len(keys))
+    for i, k := range keys {
+        keyMap[cacheKeys[i]] = k
+    }
+    bf := func(misses []string) (map[string]interface{}, error) {
+        missKeys := make([]K, 0, len(misses))
+        for _, m := range misses {
+            missKeys = append(missKeys, keyMap[m])
+        }
+        loaded, err := loader(missKeys)
+        if err != nil {
+            return nil, err
+        }
+        rs := make(map[string]interface{}, len(loaded))
+        for k, v := range loaded {
+            rs[c.keyFunc(k)] = v
+        }
+        return rs, nil
+    }
+    var values map[string]V
+    if err := BCallMany(&values, c.b, cacheKeys, c.expire, bf, c.opts...); err != nil {
+        return nil, err
+    }
+    ret := make(map[K]V, len(values))
+    for cacheKey, v := range values {
+        ret[keyMap[cacheKey]] = v
+    }
+    return ret, nil
+}
+
+// cacheKeys 将业务主键转换为缓存key
+func (c *Cache[K, V]) cacheKeys(keys []K) []string {
+    cacheKeys := make([]string, len(keys))
+    for i, k := range keys {
+        cacheKeys[i] = c.keyFunc(k)
+    }
+    return cacheKeys
+}
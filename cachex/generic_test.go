@@ -0,0 +1,76 @@
+package cachex
+
+import (
+    "testing"
+)
+
+func TestGetOrLoad(t *testing.T) {
+    b := NewMemoryBackend(0)
+    calls := 0
+    loader := func() (batchUser, error) {
+        calls++
+        return batchUser{ID: 7, Name: "g"}, nil
+    }
+    for i := 0; i < 2; i++ {
+        u, err := GetOrLoad[batchUser](b, "gu:7", 60, loader)
+        if err != nil || u.ID != 7 {
+            t.Fatalf("unexpected result: %+v, %v", u, err)
+        }
+    }
+    if calls != 1 {
+        t.Fatalf("loader should be called once, got %d", calls)
+    }
+    if _, err := Get[batchUser](b, "gu:8"); err != ErrNil {
+        t.Fatalf("expect ErrNil, got %v", err)
+    }
+}
+
+func TestGetOrLoadDirect(t *testing.T) {
+    type session struct {
+        ID    int
+        Cache map[string]int `json:"-"` // 不会被缓存的字段
+    }
+    b := NewMemoryBackend(0)
+    loader := func() (session, error) {
+        return session{ID: 1, Cache: map[string]int{"n": 1}}, nil
+    }
+    // 执行loader时直接返回loader的结果，不经过编码和解码
+    s, err := GetOrLoad[session](b, "gs:1", 60, loader, WithSingleflight())
+    if err != nil || s.ID != 1 || s.Cache["n"] != 1 {
+        t.Fatalf("loaded value should be returned directly: %+v, %v", s, err)
+    }
+    s, err = GetOrLoad[session](b, "gs:1", 60, loader)
+    if err != nil || s.ID != 1 || s.Cache != nil {
+        t.Fatalf("cached value should be decoded: %+v, %v", s, err)
+    }
+}
+
+func TestCache(t *testing.T) {
+    b := NewMemoryBackend(0)
+    c := NewCache[int, batchUser](b, KeyFormat[int]("user:%d"), 60)
+    if err := c.Set(1, batchUser{ID: 1}); err != nil {
+        t.Fatal(err)
+    }
+    u, err := c.Get(1)
+    if err != nil || u.ID != 1 {
+        t.Fatalf("unexpected result: %+v, %v", u, err)
+    }
+    users, misses, err := c.GetMany([]int{1, 2})
+    if err != nil || len(users) != 1 || len(misses) != 1 || misses[0] != 2 {
+        t.Fatalf("unexpected result: %v, %v, %v", users, misses, err)
+    }
+    users, err = c.GetOrLoadMany([]int{1, 2, 3}, func(ids []int) (map[int]batchUser, error) {
+        rs := make(map[int]batchUser)
+        for _, id := range ids {
+            rs[id] = batchUser{ID: id}
+        }
+        return rs, nil
+    })
+    if err != nil || len(users) != 3 || users[3].ID != 3 {
+        t.Fatalf("unexpected result: %v, %v", users, err)
+    }
+    c.Delete(1, 2, 3)
+    if _, err = c.Get(2); err != ErrNil {
+        t.Fatalf("expect ErrNil, got %v", err)
+    }
+}
@@ -0,0 +1,111 @@
+package cachex
+
+import (
+    "bytes"
+    "errors"
+
+    "github.com/gomodule/redigo/redis"
+)
+
+const (
+    idempotencyKeyPrefix = "idem:" // 幂等key的前缀
+)
+
+// ErrIdempotencyInProgress 相同幂等key的请求正在处理中
+var ErrIdempotencyInProgress = errors.New("cachex: request with the same idempotency key is in progress")
+
+// idempotencyPending 处理中状态在缓存中的存储值前缀，后面跟随处理方的token，不是合法的json，不会与正常数据冲突
+var idempotencyPending = []byte("\x00cachex:idem:pending:")
+
+// getIdempotencyKey 获取幂等key的完整key
+func getIdempotencyKey(idemKey string) string {
+    return getCacheKey(idempotencyKeyPrefix + idemKey)
+}
+
+// IdempotentCall 幂等调用，用于防止重复提交，同一个幂等key（如客户端生成的请求ID）的业务方法只会成功执行一次
+// 首次请求时记录处理中状态并调用业务方法，成功后保存结果，相同key的后续请求直接返回保存的结果，并返回true；
+// 处理中的请求返回ErrIdempotencyInProgress；业务方法失败时清除处理中状态，允许客户端重试
+// lockExpire - 处理中状态的最长保留时间，单位：秒，防止处理方异常退出后永远无法重试
+// expire - 成功结果的保留时间，单位：秒
+func IdempotentCall(ret interface{}, rds redis.Conn, idemKey string, lockExpire, expire int64, bf BizFunc) (bool, error) {
+    return BIdempotentCall(ret, NewRedisBackend(rds), idemKey, lockExpire, expire, bf)
+}
+
+// PIdempotentCall 幂等调用
+func PIdempotentCall(ret interface{}, provider Provider, idemKey string, lockExpire, expire int64, bf BizFunc) (bool, error) {
+    rds := provider.Redis()
+    defer rds.Close()
+    return IdempotentCall(ret, rds, idemKey, lockExpire, expire, bf)
+}
+
+// BIdempotentCall 幂等调用
+func BIdempotentCall(ret interface{}, b Backend, idemKey string, lockExpire, expire int64, bf BizFunc) (bool, error) {
+    cacheKey := getIdempotencyKey(idemKey)
+    pending := append(append([]byte(nil), idempotencyPending...), randomToken()...)
+    // 处理中状态可能在两次操作之间过期，此时重新尝试获取
+    for i := 0; i < 2; i++ {
+        locked, err := acquireToken(b, cacheKey, string(pending), lockExpire)
+        if err != nil {
+            return false, err
+        }
+        if locked {
+            return false, idempotentLoad(ret, b, cacheKey, pending, expire, bf)
+        }
+        data, err := b.Get(cacheKey)
+        if err == ErrNil {
+            continue
+        }
+        if err != nil {
+            return false, err
+        }
+        if bytes.HasPrefix(data, idempotencyPending) {
+            emit(EventLockContention, cacheKey, 0, nil)
+            return false, ErrIdempotencyInProgress
+        }
+        if ret != nil {
+            return true, decodeValue(data, ret)
+        }
+        return true, nil
+    }
+    return false, ErrIdempotencyInProgress
+}
+
+// idempotentLoad 持有处理中状态时调用业务方法，成功时保存结果，失败时清除处理中状态
+func idempotentLoad(ret interface{}, b Backend, cacheKey string, pending []byte, expire int64, bf BizFunc) error {
+    resp, err := bf()
+    if err != nil {
+        releaseToken(b, cacheKey, string(pending))
+        return err
+    }
+    data, err := encodeValue(nil, resp)
+    if err != nil {
+        releaseToken(b, cacheKey, string(pending))
+        return err
+    }
+    // 仅当处理中状态仍属于当前调用方时保存结果，处理中状态已经过期并被其它调用方获取时不覆盖其结果
+    // 结果保存失败时，后续请求会在处理中状态过期后重新执行
+    if _, err = replaceToken(b, cacheKey, string(pending), data, expire); err != nil {
+        emit(EventStoreError, cacheKey, 0, err)
+    }
+    if ret != nil {
+        return decodeValue(data, ret)
+    }
+    return nil
+}
+
+// ForgetIdempotency 清除幂等key保存的结果或者处理中状态，之后相同key的请求会重新执行
+func ForgetIdempotency(rds redis.Conn, idemKey string) error {
+    return BForgetIdempotency(NewRedisBackend(rds), idemKey)
+}
+
+// PForgetIdempotency 清除幂等key保存的结果或者处理中状态
+func PForgetIdempotency(provider Provider, idemKey string) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return ForgetIdempotency(rds, idemKey)
+}
+
+// BForgetIdempotency 清除幂等key保存的结果或者处理中状态
+func BForgetIdempotency(b Backend, idemKey string) error {
+    return b.Del(getIdempotencyKey(idemKey))
+}
@@ -0,0 +1,99 @@
+package cachex
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestBIdempotentCall(t *testing.T) {
+    b := NewMemoryBackend(0)
+    type order struct {
+        ID     int64  `json:"id"`
+        Status string `json:"status"`
+    }
+    calls := 0
+    bf := func() (interface{}, error) {
+        calls++
+        return &order{ID: 42, Status: "created"}, nil
+    }
+    var ret order
+    replayed, err := BIdempotentCall(&ret, b, "req-1", 10, 60, bf)
+    if err != nil || replayed || ret.ID != 42 {
+        t.Fatalf("unexpected first call: %+v, %v, %v", ret, replayed, err)
+    }
+    var again order
+    replayed, err = BIdempotentCall(&again, b, "req-1", 10, 60, bf)
+    if err != nil || !replayed || again != ret {
+        t.Fatalf("repeated call should return stored response: %+v, %v, %v", again, replayed, err)
+    }
+    if calls != 1 {
+        t.Fatalf("business func should be called once, got %d", calls)
+    }
+
+    // 清除后重新执行
+    if err = BForgetIdempotency(b, "req-1"); err != nil {
+        t.Fatal(err)
+    }
+    if replayed, _ = BIdempotentCall(&again, b, "req-1", 10, 60, bf); replayed || calls != 2 {
+        t.Fatalf("call should be executed again after forget, replayed %v, calls %d", replayed, calls)
+    }
+}
+
+func TestBIdempotentCallInProgress(t *testing.T) {
+    b := NewMemoryBackend(0)
+    var inner error
+    _, err := BIdempotentCall(nil, b, "req-2", 10, 60, func() (interface{}, error) {
+        // 处理过程中收到相同key的请求
+        _, inner = BIdempotentCall(nil, b, "req-2", 10, 60, func() (interface{}, error) {
+            return "dup", nil
+        })
+        return "ok", nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if inner != ErrIdempotencyInProgress {
+        t.Fatalf("expect ErrIdempotencyInProgress, got %v", inner)
+    }
+}
+
+func TestBIdempotentCallError(t *testing.T) {
+    b := NewMemoryBackend(0)
+    bizErr := errors.New("biz error")
+    if _, err := BIdempotentCall(nil, b, "req-3", 10, 60, func() (interface{}, error) {
+        return nil, bizErr
+    }); err != bizErr {
+        t.Fatalf("expect biz error, got %v", err)
+    }
+    // 失败后允许重试
+    var ret string
+    replayed, err := BIdempotentCall(&ret, b, "req-3", 10, 60, func() (interface{}, error) {
+        return "ok", nil
+    })
+    if err != nil || replayed || ret != "ok" {
+        t.Fatalf("retry should be executed: %q, %v, %v", ret, replayed, err)
+    }
+}
+
+func TestBIdempotentCallExpiredPending(t *testing.T) {
+    b := NewMemoryBackend(0)
+    bf := func() (interface{}, error) {
+        // 模拟处理中状态过期后，其它调用方获取并保存了结果
+        _ = b.Del(getIdempotencyKey("req-4"))
+        if _, err := BIdempotentCall(nil, b, "req-4", 10, 60, func() (interface{}, error) {
+            return "second", nil
+        }); err != nil {
+            t.Fatal(err)
+        }
+        return "first", nil
+    }
+    if _, err := BIdempotentCall(nil, b, "req-4", 10, 60, bf); err != nil {
+        t.Fatal(err)
+    }
+    // 处理中状态已经不属于第一个调用方，不能覆盖其它调用方保存的结果
+    var ret string
+    replayed, err := BIdempotentCall(&ret, b, "req-4", 10, 60, bf)
+    if err != nil || !replayed || ret != "second" {
+        t.Fatalf("unexpected result: %q, replayed: %v, err: %v", ret, replayed, err)
+    }
+}
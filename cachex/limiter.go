@@ -0,0 +1,166 @@
+package cachex
+
+import (
+    "errors"
+    "strconv"
+    "time"
+)
+
+const (
+    limiterKeyPrefix = "ratelimit:" // 限流key的前缀
+)
+
+var (
+    // ErrLimiterUnsupported Backend未实现RateLimitBackend接口
+    ErrLimiterUnsupported = errors.New("cachex: backend does not support rate limit")
+    // ErrInvalidLimitCount 请求的次数小于等于0或者超过了限流器的上限，永远不会被允许
+    ErrInvalidLimitCount = errors.New("cachex: rate limit count must be in (0, limit]")
+    // errLimitReply 限流脚本的返回值格式错误
+    errLimitReply = errors.New("cachex: unexpected rate limit reply")
+)
+
+// LimitResult 限流结果
+type LimitResult struct {
+    Allowed    bool          // 是否允许本次请求
+    Limit      int64         // 限流器的上限，令牌桶为桶的容量
+    Remaining  int64         // 剩余可用的次数
+    RetryAfter time.Duration // 被拒绝时需要等待多久才可能被允许，允许时为0
+}
+
+// newLimitResult 根据毫秒数创建限流结果
+func newLimitResult(allowed bool, limit, remaining, retryAfter int64) *LimitResult {
+    if remaining < 0 {
+        remaining = 0
+    }
+    if retryAfter < 0 {
+        retryAfter = 0
+    }
+    return &LimitResult{
+        Allowed:    allowed,
+        Limit:      limit,
+        Remaining:  remaining,
+        RetryAfter: time.Duration(retryAfter) * time.Millisecond,
+    }
+}
+
+// Limiter 限流器，key为限流的对象，如用户ID、IP或者接口名称，会自动添加缓存前缀
+type Limiter interface {
+    // Allow 请求一次，等同于AllowN(key, 1)
+    Allow(key string) (*LimitResult, error)
+    // AllowN 请求n次，被拒绝的请求不会计入限流
+    AllowN(key string, n int64) (*LimitResult, error)
+}
+
+// getLimiterKey 获取限流的完整key
+func getLimiterKey(key string) string {
+    return getCacheKey(limiterKeyPrefix + key)
+}
+
+// limitBackend 检查Backend是否支持限流
+func limitBackend(b Backend) (RateLimitBackend, error) {
+    lb, ok := b.(RateLimitBackend)
+    if !ok {
+        return nil, ErrLimiterUnsupported
+    }
+    return lb, nil
+}
+
+// fixedWindowLimiter 固定窗口限流器
+type fixedWindowLimiter struct {
+    b      Backend
+    limit  int64
+    window time.Duration
+}
+
+// NewFixedWindowLimiter 创建固定窗口限流器，每个window内最多允许limit次请求
+// 窗口按时间对齐（如每分钟的第0秒开始），实现简单、开销最小，但在窗口边界处最多可能允许2*limit次请求
+func NewFixedWindowLimiter(b Backend, limit int64, window time.Duration) Limiter {
+    return &fixedWindowLimiter{
+        b:      b,
+        limit:  limit,
+        window: window,
+    }
+}
+
+func (l *fixedWindowLimiter) Allow(key string) (*LimitResult, error) {
+    return l.AllowN(key, 1)
+}
+
+func (l *fixedWindowLimiter) AllowN(key string, n int64) (*LimitResult, error) {
+    lb, err := limitBackend(l.b)
+    if err != nil {
+        return nil, err
+    }
+    if n <= 0 || n > l.limit {
+        return nil, ErrInvalidLimitCount
+    }
+    window := lockMillis(l.window)
+    now := time.Now().UnixMilli()
+    idx := now / window
+    ttl := (idx+1)*window - now
+    return lb.FixedWindowLimit(getLimiterKey(key)+":"+strconv.FormatInt(idx, 10), l.limit, n, ttl)
+}
+
+// slidingWindowLimiter 滑动窗口限流器
+type slidingWindowLimiter struct {
+    b      Backend
+    limit  int64
+    window time.Duration
+}
+
+// NewSlidingWindowLimiter 创建滑动窗口限流器，任意连续的window时间内最多允许limit次请求
+// 使用滑动日志实现，会记录窗口内每一次被允许的请求，结果精确，但存储开销与limit成正比
+func NewSlidingWindowLimiter(b Backend, limit int64, window time.Duration) Limiter {
+    return &slidingWindowLimiter{
+        b:      b,
+        limit:  limit,
+        window: window,
+    }
+}
+
+func (l *slidingWindowLimiter) Allow(key string) (*LimitResult, error) {
+    return l.AllowN(key, 1)
+}
+
+func (l *slidingWindowLimiter) AllowN(key string, n int64) (*LimitResult, error) {
+    lb, err := limitBackend(l.b)
+    if err != nil {
+        return nil, err
+    }
+    if n <= 0 || n > l.limit {
+        return nil, ErrInvalidLimitCount
+    }
+    return lb.SlidingWindowLimit(getLimiterKey(key), l.limit, n, lockMillis(l.window), time.Now().UnixMilli(), randomToken())
+}
+
+// tokenBucketLimiter 令牌桶限流器
+type tokenBucketLimiter struct {
+    b     Backend
+    rate  float64
+    burst int64
+}
+
+// NewTokenBucketLimiter 创建令牌桶限流器，桶的容量为burst，每秒补充rate个令牌，每次请求消耗一个令牌
+// 允许最多burst次的突发请求，长期来看请求速率不超过rate
+func NewTokenBucketLimiter(b Backend, rate float64, burst int64) Limiter {
+    return &tokenBucketLimiter{
+        b:     b,
+        rate:  rate,
+        burst: burst,
+    }
+}
+
+func (l *tokenBucketLimiter) Allow(key string) (*LimitResult, error) {
+    return l.AllowN(key, 1)
+}
+
+func (l *tokenBucketLimiter) AllowN(key string, n int64) (*LimitResult, error) {
+    lb, err := limitBackend(l.b)
+    if err != nil {
+        return nil, err
+    }
+    if n <= 0 || n > l.burst || l.rate <= 0 {
+        return nil, ErrInvalidLimitCount
+    }
+    return lb.TokenBucketLimit(getLimiterKey(key), l.rate, l.burst, n, time.Now().UnixMilli())
+}
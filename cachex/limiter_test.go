@@ -0,0 +1,79 @@
+package cachex
+
+import (
+    "testing"
+    "time"
+)
+
+func TestFixedWindowLimiter(t *testing.T) {
+    l := NewFixedWindowLimiter(NewMemoryBackend(0), 3, time.Minute)
+    for i := int64(0); i < 3; i++ {
+        rs, err := l.Allow("fixed:user:1")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !rs.Allowed || rs.Remaining != 2-i {
+            t.Fatalf("request %d should be allowed: %+v", i, rs)
+        }
+    }
+    rs, err := l.Allow("fixed:user:1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rs.Allowed || rs.Remaining != 0 || rs.RetryAfter <= 0 || rs.RetryAfter > time.Minute {
+        t.Fatalf("request should be rejected: %+v", rs)
+    }
+    // 不同的key互不影响
+    if rs, _ = l.Allow("fixed:user:2"); !rs.Allowed {
+        t.Fatal("other key should be allowed")
+    }
+    if _, err = l.AllowN("fixed:user:3", 4); err != ErrInvalidLimitCount {
+        t.Fatalf("expect ErrInvalidLimitCount, got %v", err)
+    }
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+    l := NewSlidingWindowLimiter(NewMemoryBackend(0), 2, time.Millisecond*100)
+    if rs, _ := l.AllowN("sliding", 2); !rs.Allowed || rs.Remaining != 0 {
+        t.Fatalf("requests should be allowed: %+v", rs)
+    }
+    rs, err := l.Allow("sliding")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rs.Allowed || rs.RetryAfter <= 0 || rs.RetryAfter > time.Millisecond*100 {
+        t.Fatalf("request should be rejected: %+v", rs)
+    }
+    time.Sleep(rs.RetryAfter + time.Millisecond*10)
+    if rs, _ = l.Allow("sliding"); !rs.Allowed {
+        t.Fatalf("request should be allowed after window slides: %+v", rs)
+    }
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+    l := NewTokenBucketLimiter(NewMemoryBackend(0), 20, 2)
+    for i := 0; i < 2; i++ {
+        if rs, _ := l.Allow("bucket"); !rs.Allowed {
+            t.Fatalf("burst request %d should be allowed: %+v", i, rs)
+        }
+    }
+    rs, err := l.Allow("bucket")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rs.Allowed || rs.RetryAfter <= 0 || rs.RetryAfter > time.Millisecond*50 {
+        t.Fatalf("request should be rejected: %+v", rs)
+    }
+    time.Sleep(rs.RetryAfter + time.Millisecond*10)
+    if rs, _ = l.Allow("bucket"); !rs.Allowed {
+        t.Fatalf("request should be allowed after refill: %+v", rs)
+    }
+}
+
+func TestLimiterWrongType(t *testing.T) {
+    b := NewMemoryBackend(0)
+    _ = b.Set(getLimiterKey("wrong"), []byte("v"), 0)
+    if _, err := NewTokenBucketLimiter(b, 1, 1).Allow("wrong"); err != ErrWrongType {
+        t.Fatalf("expect ErrWrongType, got %v", err)
+    }
+}
@@ -0,0 +1,114 @@
+package cachex
+
+import (
+    "bytes"
+    "errors"
+    "time"
+
+    "github.com/whencome/goutil"
+)
+
+// ErrNotFound 数据不存在，BizFunc可以返回此错误表示数据不存在；当缓存的是空结果时，Call以及Fetch系列方法会返回此错误
+var ErrNotFound = errors.New("cachex: not found")
+
+// notFoundMarker 空结果在缓存中的存储值，不是合法的json，不会与正常数据冲突
+var notFoundMarker = []byte("\x00cachex:notfound")
+
+// isNotFound 判断缓存数据是否是空结果
+func isNotFound(data []byte) bool {
+    return bytes.Equal(data, notFoundMarker)
+}
+
+// loadData 在缓存未命中时加载数据，根据参数决定是否合并并发调用以及是否使用分布式锁
+// 返回序列化后的数据，业务方法返回nil时返回的数据也为nil
+func loadData(b Backend, cacheKey string, expire int64, bf BizFunc, o *callOptions) ([]byte, error) {
+    if !o.singleflight {
+        return doLoad(b, cacheKey, expire, bf, o)
+    }
+    data, err := callGroup.DoContext(o.context(), cacheKey, func() ([]byte, error) {
+        if o.loadLock {
+            return lockedLoad(b, cacheKey, expire, bf, o)
+        }
+        return doLoad(b, cacheKey, expire, bf, o)
+    })
+    // 执行方的context已经结束，但当前调用仍然有效时，自行加载
+    if isContextError(err) && o.context().Err() == nil {
+        return doLoad(b, cacheKey, expire, bf, o)
+    }
+    return data, err
+}
+
+// doLoad 调用业务方法获取数据并写入缓存，写入缓存或者关联标签失败不影响返回结果
+// 如果设置了空结果缓存时间，业务方法返回空结果时会缓存空结果标记，并返回ErrNotFound
+func doLoad(b Backend, cacheKey string, expire int64, bf BizFunc, o *callOptions) ([]byte, error) {
+    start := time.Now()
+    data, err := o.invoke(bf)
+    if err != nil && err != ErrNotFound {
+        o.emit(EventLoadError, cacheKey, time.Since(start), err)
+        return nil, err
+    }
+    o.emit(EventLoad, cacheKey, time.Since(start), nil)
+    if err == ErrNotFound || goutil.IsNil(data) {
+        if o.notFoundExpire <= 0 {
+            return nil, err
+        }
+        if e := b.Set(cacheKey, notFoundMarker, o.notFoundExpire); e != nil {
+            o.emit(EventStoreError, cacheKey, 0, e)
+        } else {
+            _ = addTags(b, o.tags, o.notFoundExpire, cacheKey)
+        }
+        return nil, ErrNotFound
+    }
+    bytesData, err := encodeValue(o.codec, data)
+    if err != nil {
+        return nil, err
+    }
+    // 缓存数据，写入失败不影响返回结果，仅通知观察者
+    entryData, ttl := o.encodeEntry(bytesData, expire, time.Since(start))
+    if e := b.Set(cacheKey, entryData, ttl); e != nil {
+        o.emit(EventStoreError, cacheKey, 0, e)
+    } else {
+        _ = addTags(b, o.tags, ttl, cacheKey)
+    }
+    return bytesData, nil
+}
+
+// lockedLoad 获取分布式加载锁后再加载数据，未获得锁时等待其它进程写入缓存
+func lockedLoad(b Backend, cacheKey string, expire int64, bf BizFunc, o *callOptions) ([]byte, error) {
+    lockKey := cacheKey + loadLockKeySuffix
+    token := randomToken()
+    locked, err := acquireToken(b, lockKey, token, o.loadLockExpire)
+    if err != nil {
+        // 锁服务异常时不影响业务，直接加载
+        return doLoad(b, cacheKey, expire, bf, o)
+    }
+    if locked {
+        // 仅释放自己持有的锁
+        defer releaseToken(b, lockKey, token)
+        // 获得锁后再检查一次缓存，可能已经被其它进程写入
+        if cacheData, e := b.Get(cacheKey); e == nil {
+            return cacheData, nil
+        }
+        return doLoad(b, cacheKey, expire, bf, o)
+    }
+
+    // 未获得锁，等待持有锁的进程写入缓存
+    o.emit(EventLockContention, lockKey, 0, nil)
+    ctx := o.context()
+    deadline := time.Now().Add(o.loadLockWait)
+    for time.Now().Before(deadline) {
+        select {
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        case <-time.After(loadLockPollInterval):
+        }
+        if cacheData, e := b.Get(cacheKey); e == nil {
+            return cacheData, nil
+        }
+        // 锁已经释放但仍然没有缓存，说明对方加载失败或者结果为空，不再等待
+        if exists, e := b.Exists(lockKey); e == nil && !exists {
+            break
+        }
+    }
+    return doLoad(b, cacheKey, expire, bf, o)
+}
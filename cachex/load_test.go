@@ -0,0 +1,34 @@
+package cachex
+
+import (
+    "testing"
+)
+
+func TestBCallNotFound(t *testing.T) {
+    b := NewMemoryBackend(0)
+    calls := 0
+    bf := func() (interface{}, error) {
+        calls++
+        return nil, nil
+    }
+    for i := 0; i < 3; i++ {
+        var ret map[string]interface{}
+        if err := BCall(&ret, b, "user:0", 60, bf, WithNotFoundTTL(10)); err != ErrNotFound {
+            t.Fatalf("expect ErrNotFound, got %v", err)
+        }
+    }
+    if calls != 1 {
+        t.Fatalf("business func should be called once, got %d", calls)
+    }
+    var ret map[string]interface{}
+    if err := BFetch(&ret, b, "user:0"); err != ErrNotFound {
+        t.Fatalf("expect ErrNotFound from fetch, got %v", err)
+    }
+    // 未开启空结果缓存时保持原有行为
+    if err := BCall(&ret, b, "user:1", 60, bf); err != nil {
+        t.Fatalf("expect nil error, got %v", err)
+    }
+    if ok, _ := BExists(b, "user:1"); ok {
+        t.Fatal("nil result should not be cached")
+    }
+}
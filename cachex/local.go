@@ -0,0 +1,263 @@
+package cachex
+
+import (
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/gomodule/redigo/redis"
+    "github.com/whencome/goutil/jsonkit"
+)
+
+// 本地缓存的默认参数
+const (
+    defaultLocalExpire     = time.Second * 5
+    defaultLocalMaxEntries = 10000
+    defaultLocalChannel    = "cachex:local:invalidate"
+    localReconnectMin      = time.Millisecond * 100
+    localReconnectMax      = time.Second * 10
+)
+
+// localCache 全局安装的本地缓存
+var localCache atomic.Pointer[LocalCache]
+
+// LocalCacheConfig 本地缓存配置
+type LocalCacheConfig struct {
+    Expire     time.Duration // 本地缓存的过期时间，默认5秒
+    MaxEntries int           // 最大条目数，超出后按LRU淘汰，默认10000
+    Channel    string        // 失效通知使用的redis频道，默认为cachex:local:invalidate
+}
+
+// LocalCache 进程内的一级缓存，位于redis之前，用于读多写少的热点数据
+// 调用Remove、Store等方法修改缓存时，会通过redis的发布订阅通知所有节点清除本地缓存
+// 失效通知在后台异步发布，不会阻塞写入缓存的调用方，多个通知会被合并发布
+type LocalCache struct {
+    mem      *MemoryBackend
+    expire   time.Duration
+    channel  string
+    provider Provider
+
+    mu     sync.Mutex
+    closed bool
+    psc    *redis.PubSubConn
+    stop   chan struct{}
+    done   chan struct{}
+
+    pubMu     sync.Mutex
+    pending   []string      // 等待发布的失效key
+    notify    chan struct{} // 有新的失效key等待发布
+    published chan struct{} // 发布协程退出时关闭
+}
+
+// NewLocalCache 创建本地缓存，provider用于发布和订阅失效通知，为nil时仅在当前进程内失效
+// 创建后需要调用SetLocalCache安装，并在Call系列方法中通过WithLocalCache启用
+func NewLocalCache(provider Provider, c *LocalCacheConfig) *LocalCache {
+    if c == nil {
+        c = &LocalCacheConfig{}
+    }
+    lc := &LocalCache{
+        mem:       NewMemoryBackend(c.MaxEntries),
+        expire:    c.Expire,
+        channel:   c.Channel,
+        provider:  provider,
+        stop:      make(chan struct{}),
+        done:      make(chan struct{}),
+        notify:    make(chan struct{}, 1),
+        published: make(chan struct{}),
+    }
+    // 本地缓存的淘汰不通知观察者，避免与二级缓存的统计混在一起
+    lc.mem.silent = true
+    if lc.expire <= 0 {
+        lc.expire = defaultLocalExpire
+    }
+    if c.MaxEntries <= 0 {
+        lc.mem.maxEntries = defaultLocalMaxEntries
+    }
+    if lc.channel == "" {
+        lc.channel = defaultLocalChannel
+    }
+    if provider != nil {
+        go lc.subscribe()
+        go lc.publish()
+    } else {
+        close(lc.done)
+        close(lc.published)
+    }
+    return lc
+}
+
+// SetLocalCache 安装全局的本地缓存，传入nil表示移除
+func SetLocalCache(lc *LocalCache) {
+    localCache.Store(lc)
+}
+
+// WithLocalCache 在Call系列方法中启用本地缓存（需要先通过SetLocalCache安装）
+func WithLocalCache() CallOption {
+    return func(o *callOptions) {
+        o.local = true
+    }
+}
+
+// getLocalCache 获取本次调用使用的本地缓存
+func (o *callOptions) getLocalCache() *LocalCache {
+    if !o.local {
+        return nil
+    }
+    return localCache.Load()
+}
+
+// get 获取本地缓存的数据
+func (lc *LocalCache) get(key string) ([]byte, bool) {
+    data, err := lc.mem.Get(key)
+    return data, err == nil
+}
+
+// set 写入本地缓存
+func (lc *LocalCache) set(key string, data []byte) {
+    lc.mem.setTTL(key, data, lc.expire)
+}
+
+// Invalidate 清除当前进程的本地缓存，并在后台通知其它节点清除，keys为完整的缓存key
+func (lc *LocalCache) Invalidate(keys ...string) {
+    if len(keys) == 0 {
+        return
+    }
+    _ = lc.mem.Del(keys...)
+    if lc.provider == nil {
+        return
+    }
+    select {
+    case <-lc.stop:
+        return
+    default:
+    }
+    lc.pubMu.Lock()
+    lc.pending = append(lc.pending, keys...)
+    lc.pubMu.Unlock()
+    select {
+    case lc.notify <- struct{}{}:
+    default:
+    }
+}
+
+// publish 在后台发布失效通知，直到调用Close，退出前发布剩余的通知
+func (lc *LocalCache) publish() {
+    defer close(lc.published)
+    for {
+        select {
+        case <-lc.notify:
+            lc.flush()
+        case <-lc.stop:
+            lc.flush()
+            return
+        }
+    }
+}
+
+// flush 将等待发布的失效key合并为一条通知发布
+func (lc *LocalCache) flush() {
+    lc.pubMu.Lock()
+    keys := lc.pending
+    lc.pending = nil
+    lc.pubMu.Unlock()
+    if len(keys) == 0 {
+        return
+    }
+    msg, err := jsonkit.Marshal(keys)
+    if err != nil {
+        return
+    }
+    rds := lc.provider.Redis()
+    defer rds.Close()
+    _, _ = rds.Do("PUBLISH", lc.channel, msg)
+}
+
+// Len 返回本地缓存的条目数
+func (lc *LocalCache) Len() int {
+    return lc.mem.Len()
+}
+
+// Close 发布剩余的失效通知并停止订阅
+func (lc *LocalCache) Close() {
+    lc.mu.Lock()
+    if lc.closed {
+        lc.mu.Unlock()
+        return
+    }
+    lc.closed = true
+    close(lc.stop)
+    // 取消订阅后接收方会收到订阅数为0的通知并退出
+    if lc.psc != nil {
+        _ = lc.psc.Unsubscribe()
+    }
+    lc.mu.Unlock()
+    <-lc.done
+    <-lc.published
+}
+
+// subscribe 订阅失效通知，连接断开后按退避策略重连，直到调用Close
+func (lc *LocalCache) subscribe() {
+    defer close(lc.done)
+    wait := localReconnectMin
+    for {
+        // 在锁内完成订阅，保证Close发出的取消订阅一定在订阅之后
+        lc.mu.Lock()
+        if lc.closed {
+            lc.mu.Unlock()
+            return
+        }
+        psc := &redis.PubSubConn{Conn: lc.provider.Redis()}
+        err := psc.Subscribe(lc.channel)
+        if err == nil {
+            lc.psc = psc
+        }
+        lc.mu.Unlock()
+
+        if err == nil {
+            wait = localReconnectMin
+            lc.receive(psc)
+        }
+        lc.mu.Lock()
+        lc.psc = nil
+        lc.mu.Unlock()
+        _ = psc.Close()
+
+        // 重连期间可能错过失效通知，清空本地缓存
+        lc.mem.clear()
+        select {
+        case <-lc.stop:
+            return
+        case <-time.After(wait):
+        }
+        wait *= 2
+        if wait > localReconnectMax {
+            wait = localReconnectMax
+        }
+    }
+}
+
+// receive 接收失效通知，直到连接出错或者取消订阅
+func (lc *LocalCache) receive(psc *redis.PubSubConn) {
+    for {
+        switch v := psc.Receive().(type) {
+        case redis.Message:
+            var keys []string
+            if err := jsonkit.Unmarshal(v.Data, &keys); err == nil && len(keys) > 0 {
+                _ = lc.mem.Del(keys...)
+            }
+        case redis.Subscription:
+            if v.Count == 0 {
+                return
+            }
+        case error:
+            return
+        }
+    }
+}
+
+// invalidateLocal 如果安装了本地缓存，清除指定的完整缓存key并通知其它节点
+func invalidateLocal(keys ...string) {
+    if lc := localCache.Load(); lc != nil {
+        lc.Invalidate(keys...)
+    }
+}
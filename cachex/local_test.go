@@ -0,0 +1,116 @@
+package cachex
+
+import (
+    "testing"
+    "time"
+)
+
+func TestLocalCache(t *testing.T) {
+    lc := NewLocalCache(nil, &LocalCacheConfig{MaxEntries: 10})
+    SetLocalCache(lc)
+    defer func() {
+        SetLocalCache(nil)
+        lc.Close()
+    }()
+
+    b := NewMemoryBackend(0)
+    calls := 0
+    bf := func() (interface{}, error) {
+        calls++
+        return "v1", nil
+    }
+    var ret string
+    if err := BCall(&ret, b, "conf:site", 60, bf, WithLocalCache()); err != nil || ret != "v1" {
+        t.Fatalf("unexpected result: %s, %v", ret, err)
+    }
+    // 直接修改二级缓存，本地缓存未过期时仍然返回本地数据
+    _ = b.Set(getCacheKey("conf:site"), []byte(`"v2"`), 60)
+    if err := BCall(&ret, b, "conf:site", 60, bf, WithLocalCache()); err != nil || ret != "v1" {
+        t.Fatalf("expect local value v1, got %s, %v", ret, err)
+    }
+    // 未启用本地缓存的调用直接读取二级缓存
+    if err := BCall(&ret, b, "conf:site", 60, bf); err != nil || ret != "v2" {
+        t.Fatalf("expect v2, got %s, %v", ret, err)
+    }
+    // 通过Store修改缓存时清除本地缓存
+    _ = BStore(b, "conf:site", 60, "v3")
+    if err := BCall(&ret, b, "conf:site", 60, bf, WithLocalCache()); err != nil || ret != "v3" {
+        t.Fatalf("expect v3, got %s, %v", ret, err)
+    }
+    BRemove(b, "conf:site")
+    if lc.Len() != 0 {
+        t.Fatalf("local cache should be empty, got %d", lc.Len())
+    }
+    if calls != 1 {
+        t.Fatalf("business func should be called once, got %d", calls)
+    }
+}
+
+func TestLocalCacheCrossNode(t *testing.T) {
+    mr, provider := newTestProvider(t)
+    channel := "cachex:test:invalidate"
+    // 两个节点各自拥有本地缓存，共享同一个redis
+    node1 := NewLocalCache(provider, &LocalCacheConfig{Channel: channel, Expire: time.Minute})
+    defer node1.Close()
+    node2 := NewLocalCache(provider, &LocalCacheConfig{Channel: channel, Expire: time.Minute})
+    defer node2.Close()
+    deadline := time.Now().Add(time.Second * 3)
+    for mr.PubSubNumSub(channel)[channel] < 2 {
+        if time.Now().After(deadline) {
+            t.Fatal("local caches did not subscribe")
+        }
+        time.Sleep(time.Millisecond * 5)
+    }
+
+    b := NewProviderBackend(provider)
+    key := getCacheKey("cross:1")
+    var ret string
+    bf := func() (interface{}, error) {
+        return "v1", nil
+    }
+    // 节点2读取数据后写入本地缓存
+    SetLocalCache(node2)
+    if err := BCall(&ret, b, "cross:1", 60, bf, WithLocalCache()); err != nil || ret != "v1" {
+        t.Fatalf("unexpected result: %s, %v", ret, err)
+    }
+    if _, ok := node2.get(key); !ok {
+        t.Fatal("value should be cached locally on node2")
+    }
+    // 节点1写入数据后，节点2的本地缓存被清除
+    SetLocalCache(node1)
+    defer SetLocalCache(nil)
+    if err := BStore(b, "cross:1", 60, "v2"); err != nil {
+        t.Fatal(err)
+    }
+    for {
+        if _, ok := node2.get(key); !ok {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatal("local cache on node2 should be invalidated")
+        }
+        time.Sleep(time.Millisecond * 5)
+    }
+    SetLocalCache(node2)
+    if err := BCall(&ret, b, "cross:1", 60, bf, WithLocalCache()); err != nil || ret != "v2" {
+        t.Fatalf("expect v2 on node2, got %s, %v", ret, err)
+    }
+}
+
+func TestLocalCacheEvictionSilent(t *testing.T) {
+    counters := NewCounters()
+    SetObserver(counters, nil)
+    defer SetObserver(nil, nil)
+
+    lc := NewLocalCache(nil, &LocalCacheConfig{MaxEntries: 1})
+    defer lc.Close()
+    lc.set("silent:1", []byte("v"))
+    lc.set("silent:2", []byte("v"))
+    if lc.Len() != 1 {
+        t.Fatalf("expect 1 local entry, got %d", lc.Len())
+    }
+    // 本地缓存的淘汰不计入二级缓存的统计
+    if len(counters.Snapshot()) != 0 {
+        t.Fatalf("local evictions should not be observed: %+v", counters.Snapshot())
+    }
+}
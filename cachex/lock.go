@@ -0,0 +1,319 @@
+package cachex
+
+import (
+    "context"
+    "errors"
+    "math/rand"
+    "sync"
+    "time"
+)
+
+var (
+    // ErrLockNotObtained 锁已经被其它调用方持有，获取失败
+    ErrLockNotObtained = errors.New("cachex: lock not obtained")
+    // ErrLockNotHeld 当前调用方未持有锁（可能已经过期并被其它调用方获取）
+    ErrLockNotHeld = errors.New("cachex: lock not held")
+    // ErrLockUnsupported Backend未实现LockBackend接口
+    ErrLockUnsupported = errors.New("cachex: backend does not support lock")
+    // ErrLockWatchdogUnsupported Backend不能被多个goroutine同时使用，无法启用自动续期
+    ErrLockWatchdogUnsupported = errors.New("cachex: lock watchdog requires a concurrent safe backend")
+    // ErrInvalidLockTTL 锁的过期时间小于等于0，无法保证互斥
+    ErrInvalidLockTTL = errors.New("cachex: lock ttl must be positive")
+)
+
+// 默认的锁重试参数
+const (
+    defaultLockRetryMin = time.Millisecond * 50
+    defaultLockRetryMax = time.Second
+)
+
+// lockOptions 锁的可选参数
+type lockOptions struct {
+    retryMin time.Duration // 首次重试的等待时间
+    retryMax time.Duration // 重试等待时间的上限
+    watchdog bool          // 是否在持有锁期间自动续期
+}
+
+// LockOption 设置锁的可选参数
+type LockOption func(o *lockOptions)
+
+// WithLockRetry 设置阻塞获取锁时的重试等待时间，每次重试等待时间翻倍（带有随机抖动），直到达到max
+func WithLockRetry(min, max time.Duration) LockOption {
+    return func(o *lockOptions) {
+        if min > 0 {
+            o.retryMin = min
+        }
+        if max >= o.retryMin {
+            o.retryMax = max
+        }
+    }
+}
+
+// WithLockWatchdog 在持有锁期间每隔ttl/3自动续期，直到调用Unlock，要求Backend可以被多个goroutine同时使用
+func WithLockWatchdog() LockOption {
+    return func(o *lockOptions) {
+        o.watchdog = true
+    }
+}
+
+// Lock 基于缓存的分布式锁，每个Lock对象使用一个随机token标识持有者，只有持有者才能续期和释放锁
+// 一个Lock对象同一时间只能被一个调用方持有，释放后可以再次获取
+type Lock struct {
+    b     LockBackend
+    err   error
+    key   string
+    token string
+    ttl   time.Duration
+    opts  *lockOptions
+
+    mu       sync.Mutex
+    held     bool
+    stopDog  chan struct{}
+    dogExits chan struct{}
+}
+
+// NewLock 创建一个分布式锁，key会自动添加缓存前缀，ttl为锁的过期时间，必须大于0
+func NewLock(b Backend, key string, ttl time.Duration, opts ...LockOption) *Lock {
+    o := &lockOptions{
+        retryMin: defaultLockRetryMin,
+        retryMax: defaultLockRetryMax,
+    }
+    for _, opt := range opts {
+        if opt != nil {
+            opt(o)
+        }
+    }
+    l := &Lock{
+        key:   getCacheKey(key),
+        token: randomToken(),
+        ttl:   ttl,
+        opts:  o,
+    }
+    if ttl <= 0 {
+        l.err = ErrInvalidLockTTL
+        return l
+    }
+    lb, ok := b.(LockBackend)
+    if !ok {
+        l.err = ErrLockUnsupported
+        return l
+    }
+    l.b = lb
+    if o.watchdog {
+        if _, ok := b.(concurrentBackend); !ok {
+            l.err = ErrLockWatchdogUnsupported
+        }
+    }
+    return l
+}
+
+// Key 返回锁对应的完整key
+func (l *Lock) Key() string {
+    return l.key
+}
+
+// Token 返回锁持有者的token
+func (l *Lock) Token() string {
+    return l.token
+}
+
+// TryLock 尝试获取锁，锁已经被持有时立即返回ErrLockNotObtained
+func (l *Lock) TryLock() error {
+    return l.tryLock(true)
+}
+
+// tryLock 尝试获取锁，contention为true时在获取失败时通知观察者
+func (l *Lock) tryLock(contention bool) error {
+    if l.err != nil {
+        return l.err
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.held {
+        return ErrLockNotObtained
+    }
+    ok, err := l.b.AcquireLock(l.key, l.token, l.ttl)
+    if err != nil {
+        return err
+    }
+    if !ok {
+        if contention {
+            emit(EventLockContention, l.key, 0, nil)
+        }
+        return ErrLockNotObtained
+    }
+    l.held = true
+    if l.opts.watchdog {
+        l.startWatchdog()
+    }
+    return nil
+}
+
+// Lock 阻塞获取锁，获取失败时按退避策略重试，直到获取成功或者ctx结束
+// 每次调用只在首次获取失败时通知一次锁竞争
+func (l *Lock) Lock(ctx context.Context) error {
+    wait := l.opts.retryMin
+    for contention := true; ; contention = false {
+        err := l.tryLock(contention)
+        if err != ErrLockNotObtained {
+            return err
+        }
+        // 添加随机抖动，避免多个调用方同时重试
+        d := wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+        timer := time.NewTimer(d)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return ctx.Err()
+        case <-timer.C:
+        }
+        wait *= 2
+        if wait > l.opts.retryMax {
+            wait = l.opts.retryMax
+        }
+    }
+}
+
+// Refresh 延长锁的过期时间，锁已经不再由当前调用方持有时返回ErrLockNotHeld，ttl小于等于0时返回ErrInvalidLockTTL
+func (l *Lock) Refresh(ttl time.Duration) error {
+    if l.err != nil {
+        return l.err
+    }
+    if ttl <= 0 {
+        return ErrInvalidLockTTL
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if !l.held {
+        return ErrLockNotHeld
+    }
+    ok, err := l.b.RenewLock(l.key, l.token, ttl)
+    if err != nil {
+        return err
+    }
+    if !ok {
+        l.held = false
+        l.stopWatchdog()
+        return ErrLockNotHeld
+    }
+    return nil
+}
+
+// Unlock 释放锁，只会删除当前调用方持有的锁，锁已经过期或者被其它调用方持有时返回ErrLockNotHeld
+func (l *Lock) Unlock() error {
+    if l.err != nil {
+        return l.err
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if !l.held {
+        return ErrLockNotHeld
+    }
+    l.held = false
+    l.stopWatchdog()
+    ok, err := l.b.ReleaseLock(l.key, l.token)
+    if err != nil {
+        return err
+    }
+    if !ok {
+        return ErrLockNotHeld
+    }
+    return nil
+}
+
+// startWatchdog 启动自动续期，调用方需持有l.mu
+func (l *Lock) startWatchdog() {
+    interval := l.ttl / 3
+    if interval <= 0 {
+        interval = time.Millisecond
+    }
+    stop := make(chan struct{})
+    exits := make(chan struct{})
+    l.stopDog = stop
+    l.dogExits = exits
+    go func() {
+        lost := false
+        defer func() {
+            // 先通知退出，避免持有l.mu等待退出的stopWatchdog与lost互相等待
+            close(exits)
+            if lost {
+                l.lost(stop)
+            }
+        }()
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-stop:
+                return
+            case <-ticker.C:
+                // 续期失败说明锁已经丢失，不再继续续期
+                if ok, err := l.b.RenewLock(l.key, l.token, l.ttl); err == nil && !ok {
+                    lost = true
+                    return
+                }
+            }
+        }
+    }()
+}
+
+// lost 自动续期发现锁已经丢失时清除持有状态，stop用于判断是否仍是当前的自动续期
+func (l *Lock) lost(stop chan struct{}) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    // 已经被Unlock停止或者重新获取了锁
+    if l.stopDog != stop {
+        return
+    }
+    l.held = false
+    l.stopDog = nil
+    l.dogExits = nil
+}
+
+// stopWatchdog 停止自动续期并等待其退出，调用方需持有l.mu
+func (l *Lock) stopWatchdog() {
+    if l.stopDog == nil {
+        return
+    }
+    close(l.stopDog)
+    <-l.dogExits
+    l.stopDog = nil
+    l.dogExits = nil
+}
+
+// acquireToken 使用token获取一个简单的锁，过期时间单位为秒，Backend未实现LockBackend时使用SetNX代替
+func acquireToken(b Backend, key, token string, expire int64) (bool, error) {
+    if lb, ok := b.(LockBackend); ok {
+        return lb.AcquireLock(key, token, time.Duration(expire)*time.Second)
+    }
+    return b.SetNX(key, []byte(token), expire)
+}
+
+// releaseToken 释放token对应的锁，Backend未实现LockBackend时先比较再删除（非原子操作）
+func releaseToken(b Backend, key, token string) {
+    if lb, ok := b.(LockBackend); ok {
+        _, _ = lb.ReleaseLock(key, token)
+        return
+    }
+    if v, err := b.Get(key); err == nil && string(v) == token {
+        _ = b.Del(key)
+    }
+}
+
+// replaceToken 当key的值为token时将key设置为value，过期时间单位为秒，Backend未实现LockBackend时先比较再设置（非原子操作）
+func replaceToken(b Backend, key, token string, value []byte, expire int64) (bool, error) {
+    if lb, ok := b.(LockBackend); ok {
+        return lb.ReplaceLock(key, token, value, expire)
+    }
+    v, err := b.Get(key)
+    if err == ErrNil {
+        return false, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    if string(v) != token {
+        return false, nil
+    }
+    return true, b.Set(key, value, expire)
+}
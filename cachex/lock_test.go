@@ -0,0 +1,173 @@
+package cachex
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestLock(t *testing.T) {
+    b := NewMemoryBackend(0)
+    l1 := NewLock(b, "order:1", time.Second)
+    l2 := NewLock(b, "order:1", time.Second)
+    if err := l1.TryLock(); err != nil {
+        t.Fatal(err)
+    }
+    if err := l2.TryLock(); err != ErrLockNotObtained {
+        t.Fatalf("expect ErrLockNotObtained, got %v", err)
+    }
+    // 非持有者无法释放锁
+    if err := l2.Unlock(); err != ErrLockNotHeld {
+        t.Fatalf("expect ErrLockNotHeld, got %v", err)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+    defer cancel()
+    if err := l2.Lock(ctx); err != context.DeadlineExceeded {
+        t.Fatalf("expect deadline exceeded, got %v", err)
+    }
+    if err := l1.Unlock(); err != nil {
+        t.Fatal(err)
+    }
+    if err := l2.Lock(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    _ = l2.Unlock()
+}
+
+func TestLockExpiredRelease(t *testing.T) {
+    b := NewMemoryBackend(0)
+    l1 := NewLock(b, "order:2", time.Millisecond*100)
+    l2 := NewLock(b, "order:2", time.Second)
+    _ = l1.TryLock()
+    time.Sleep(time.Millisecond * 150)
+    if err := l2.TryLock(); err != nil {
+        t.Fatal(err)
+    }
+    // l1的锁已经过期，释放时不能删除l2持有的锁
+    if err := l1.Unlock(); err != ErrLockNotHeld {
+        t.Fatalf("expect ErrLockNotHeld, got %v", err)
+    }
+    if ok, _ := BExists(b, "order:2"); !ok {
+        t.Fatal("lock of l2 should be kept")
+    }
+}
+
+func TestLockWatchdog(t *testing.T) {
+    b := NewMemoryBackend(0)
+    l := NewLock(b, "order:3", time.Millisecond*150, WithLockWatchdog())
+    if err := l.TryLock(); err != nil {
+        t.Fatal(err)
+    }
+    time.Sleep(time.Millisecond * 400)
+    if ok, _ := BExists(b, "order:3"); !ok {
+        t.Fatal("lock should be renewed by watchdog")
+    }
+    if err := l.Unlock(); err != nil {
+        t.Fatal(err)
+    }
+    // 单连接的Backend无法启用自动续期
+    if err := NewLock(NewRedisBackend(nil), "order:4", time.Second, WithLockWatchdog()).TryLock(); err != ErrLockWatchdogUnsupported {
+        t.Fatalf("expect ErrLockWatchdogUnsupported, got %v", err)
+    }
+}
+
+func TestLockWatchdogLost(t *testing.T) {
+    b := NewMemoryBackend(0)
+    l := NewLock(b, "order:5", time.Millisecond*150, WithLockWatchdog())
+    if err := l.TryLock(); err != nil {
+        t.Fatal(err)
+    }
+    // 模拟锁被删除后由其它调用方获取
+    _ = b.Del(l.Key())
+    other := NewLock(b, "order:5", time.Second)
+    if err := other.TryLock(); err != nil {
+        t.Fatal(err)
+    }
+    time.Sleep(time.Millisecond * 150)
+    if err := l.Refresh(time.Second); err != ErrLockNotHeld {
+        t.Fatalf("expect ErrLockNotHeld, got %v", err)
+    }
+    if err := l.Unlock(); err != ErrLockNotHeld {
+        t.Fatalf("expect ErrLockNotHeld, got %v", err)
+    }
+    // 锁丢失后可以重新获取
+    _ = other.Unlock()
+    if err := l.TryLock(); err != nil {
+        t.Fatal(err)
+    }
+    _ = l.Unlock()
+}
+
+func TestBLockCall(t *testing.T) {
+    b := NewMemoryBackend(0)
+    bf := func() (interface{}, error) {
+        return "ok", nil
+    }
+    var ret string
+    if err := BLockCall(&ret, b, "submit:1", 10, false, bf); err != nil || ret != "ok" {
+        t.Fatalf("unexpected result: %s, %v", ret, err)
+    }
+    if err := BLockCall(&ret, b, "submit:1", 10, false, bf); err != ErrLockNotObtained {
+        t.Fatalf("expect ErrLockNotObtained, got %v", err)
+    }
+    if err := BLockCall(&ret, b, "submit:2", 10, true, bf); err != nil {
+        t.Fatal(err)
+    }
+    if err := BLockCall(&ret, b, "submit:2", 10, true, bf); err != nil {
+        t.Fatalf("lock should be released automatically, got %v", err)
+    }
+}
+
+// plainBackend 只实现Backend接口，用于测试未实现扩展接口时的降级处理
+type plainBackend struct {
+    Backend
+}
+
+func TestBLockCallFallback(t *testing.T) {
+    bf := func() (interface{}, error) {
+        return "ok", nil
+    }
+    if err := BLockCall(nil, NewMemoryBackend(0), "submit:3", 0, true, bf); err != ErrInvalidLockTTL {
+        t.Fatalf("expect ErrInvalidLockTTL, got %v", err)
+    }
+    if err := NewLock(NewMemoryBackend(0), "submit:3", 0).TryLock(); err != ErrInvalidLockTTL {
+        t.Fatalf("expect ErrInvalidLockTTL, got %v", err)
+    }
+    // 未实现LockBackend时使用SetNX获取锁
+    b := plainBackend{NewMemoryBackend(0)}
+    if err := BLockCall(nil, b, "submit:4", 10, false, bf); err != nil {
+        t.Fatal(err)
+    }
+    if err := BLockCall(nil, b, "submit:4", 10, false, bf); err != ErrLockNotObtained {
+        t.Fatalf("expect ErrLockNotObtained, got %v", err)
+    }
+    if err := BLockCall(nil, b, "submit:5", 10, true, bf); err != nil {
+        t.Fatal(err)
+    }
+    if err := BLockCall(nil, b, "submit:5", 10, true, bf); err != nil {
+        t.Fatalf("lock should be released automatically, got %v", err)
+    }
+}
+
+func TestLockContentionEvent(t *testing.T) {
+    counters := NewCounters()
+    SetObserver(counters, nil)
+    defer SetObserver(nil, nil)
+
+    b := NewMemoryBackend(0)
+    holder := NewLock(b, "contention:1", time.Second)
+    if err := holder.TryLock(); err != nil {
+        t.Fatal(err)
+    }
+    defer holder.Unlock()
+    // 多次重试只通知一次锁竞争
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+    defer cancel()
+    l := NewLock(b, "contention:1", time.Second, WithLockRetry(time.Millisecond*5, time.Millisecond*10))
+    if err := l.Lock(ctx); err != context.DeadlineExceeded {
+        t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+    }
+    if n := counters.Snapshot()["cachex:contention:*"].LockContentions; n != 1 {
+        t.Fatalf("expect 1 lock contention, got %d", n)
+    }
+}
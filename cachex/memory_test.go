@@ -0,0 +1,144 @@
+package cachex
+
+import (
+    "testing"
+    "time"
+)
+
+func TestMemoryBackendExpire(t *testing.T) {
+    b := NewMemoryBackend(0)
+    if err := b.Set("k", []byte("v"), 1); err != nil {
+        t.Fatal(err)
+    }
+    v, err := b.Get("k")
+    if err != nil || string(v) != "v" {
+        t.Fatalf("unexpected value: %s, %v", v, err)
+    }
+    time.Sleep(time.Millisecond * 1100)
+    if _, err = b.Get("k"); err != ErrNil {
+        t.Fatalf("expect ErrNil after expired, got %v", err)
+    }
+}
+
+func TestMemoryBackendEvict(t *testing.T) {
+    b := NewMemoryBackend(2)
+    _ = b.Set("a", []byte("1"), 0)
+    _ = b.Set("b", []byte("2"), 0)
+    // 访问a，使b成为最久未使用的数据
+    _, _ = b.Get("a")
+    _ = b.Set("c", []byte("3"), 0)
+    if ok, _ := b.Exists("b"); ok {
+        t.Fatal("b should be evicted")
+    }
+    if ok, _ := b.Exists("a"); !ok {
+        t.Fatal("a should be kept")
+    }
+    if b.Len() != 2 {
+        t.Fatalf("unexpected size: %d", b.Len())
+    }
+}
+
+func TestMemoryBackendEvictTags(t *testing.T) {
+    b := NewMemoryBackend(2)
+    if err := b.TagAdd("tag", 0, "a", "b"); err != nil {
+        t.Fatal(err)
+    }
+    _ = b.Set("a", []byte("1"), 0)
+    _ = b.Set("b", []byte("2"), 0)
+    _ = b.Set("c", []byte("3"), 0)
+    // 标签集合不参与淘汰，也不占用最大条目数
+    if ok, _ := b.Exists("a"); ok {
+        t.Fatal("a should be evicted")
+    }
+    if ok, _ := b.Exists("b"); !ok {
+        t.Fatal("b should be kept")
+    }
+    members, err := b.TagPop("tag")
+    if err != nil || len(members) != 2 {
+        t.Fatalf("tag set should be kept, got %v, %v", members, err)
+    }
+}
+
+func TestMemoryBackendEvictBitmap(t *testing.T) {
+    b := NewMemoryBackend(2)
+    f := NewBloomFilter(b, "evict", 100, 0.01)
+    if err := f.Add("x"); err != nil {
+        t.Fatal(err)
+    }
+    for _, k := range []string{"a", "b", "c", "d"} {
+        _ = b.Set(k, []byte(k), 0)
+    }
+    // 位图不参与淘汰，否则布隆过滤器会误判元素一定不存在
+    if ok, err := f.Exists("x"); err != nil || !ok {
+        t.Fatalf("bloom filter should survive eviction: %v, %v", ok, err)
+    }
+    if ok, _ := b.Exists("b"); ok {
+        t.Fatal("b should be evicted")
+    }
+    if ok, _ := b.Exists("c"); !ok {
+        t.Fatal("c should be kept")
+    }
+    // 位图被覆盖为普通数据后重新参与淘汰
+    _ = b.Set(f.key, []byte("v"), 0)
+    _ = b.Set("e", []byte("e"), 0)
+    if b.Len() != 2 {
+        t.Fatalf("unexpected size: %d", b.Len())
+    }
+}
+
+func TestMemoryBackendBitAndIncr(t *testing.T) {
+    b := NewMemoryBackend(0)
+    if _, err := b.SetBit("bits", 9, 1); err != nil {
+        t.Fatal(err)
+    }
+    if v, _ := b.GetBit("bits", 9); v != 1 {
+        t.Fatal("bit 9 should be set")
+    }
+    if v, _ := b.GetBit("bits", 8); v != 0 {
+        t.Fatal("bit 8 should not be set")
+    }
+    // 与redis一致，集合类型的数据不能进行位操作
+    if err := b.TagAdd("set", 0, "a"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := b.SetBit("set", 1, 1); err != ErrWrongType {
+        t.Fatalf("expect ErrWrongType, got %v", err)
+    }
+    if err := b.SetBits("set", []int64{1}, 0); err != ErrWrongType {
+        t.Fatalf("expect ErrWrongType, got %v", err)
+    }
+    if _, err := b.GetBit("set", 1); err != ErrWrongType {
+        t.Fatalf("expect ErrWrongType, got %v", err)
+    }
+    for i := 0; i < 3; i++ {
+        _, _ = b.IncrBy("n", 1)
+    }
+    if n, _ := b.IncrBy("n", -1); n != 2 {
+        t.Fatalf("unexpected counter: %d", n)
+    }
+}
+
+func TestBCallWithMemoryBackend(t *testing.T) {
+    b := NewMemoryBackend(100)
+    calls := 0
+    bf := func() (interface{}, error) {
+        calls++
+        return map[string]int{"id": 42}, nil
+    }
+    for i := 0; i < 3; i++ {
+        var ret map[string]int
+        if err := BCall(&ret, b, "user:42", 60, bf); err != nil {
+            t.Fatal(err)
+        }
+        if ret["id"] != 42 {
+            t.Fatalf("unexpected result: %v", ret)
+        }
+    }
+    if calls != 1 {
+        t.Fatalf("business func should be called once, got %d", calls)
+    }
+    ok, _ := b.Exists(getCacheKey("user:42"))
+    if !ok {
+        t.Fatal("cache should be stored with prefix")
+    }
+}
@@ -0,0 +1,318 @@
+package cachex
+
+import (
+    "context"
+    "net/http"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/whencome/goutil/jsonkit"
+)
+
+// EventType 缓存事件类型
+type EventType int
+
+const (
+    EventHit            EventType = iota + 1 // 命中缓存（包括命中空结果缓存）
+    EventMiss                                // 未命中缓存
+    EventLoad                                // 调用业务方法加载数据成功
+    EventLoadError                           // 调用业务方法加载数据失败
+    EventStoreError                          // 写入缓存失败
+    EventLockContention                      // 锁已经被其它调用方持有
+    EventEviction                            // 内存缓存容量已满，淘汰了未过期的数据
+)
+
+// String 返回事件类型的名称
+func (t EventType) String() string {
+    switch t {
+    case EventHit:
+        return "hit"
+    case EventMiss:
+        return "miss"
+    case EventLoad:
+        return "load"
+    case EventLoadError:
+        return "load_error"
+    case EventStoreError:
+        return "store_error"
+    case EventLockContention:
+        return "lock_contention"
+    case EventEviction:
+        return "eviction"
+    default:
+        return "unknown"
+    }
+}
+
+// EventSource 命中的数据来源
+type EventSource int
+
+const (
+    SourceBackend EventSource = iota + 1 // 缓存后端（如redis）
+    SourceLocal                          // 进程内的本地缓存
+)
+
+// String 返回数据来源的名称
+func (s EventSource) String() string {
+    switch s {
+    case SourceBackend:
+        return "backend"
+    case SourceLocal:
+        return "local"
+    default:
+        return "unknown"
+    }
+}
+
+// Event 缓存事件
+type Event struct {
+    Ctx      context.Context // 触发事件的调用的context，可以用于关联链路追踪，调用方未传入时为context.Background()
+    Type     EventType       // 事件类型
+    Source   EventSource     // 命中的数据来源，仅EventHit有效
+    Key      string          // 完整的缓存key
+    Pattern  string          // 缓存key的模式，用于按类别汇总，如cachex:user:*
+    Duration time.Duration   // 加载数据的耗时，仅EventLoad和EventLoadError有效
+    Err      error           // 发生的错误，仅EventLoadError和EventStoreError有效
+}
+
+// Observer 缓存事件的观察者，用于统计命中率、记录日志或者上报监控
+// Observe会在缓存操作的调用路径上同步执行，实现应当尽快返回，并且不能再调用cachex的方法
+type Observer interface {
+    Observe(e Event)
+}
+
+// ObserverFunc 将普通方法转换为Observer
+type ObserverFunc func(e Event)
+
+// Observe 调用f(e)
+func (f ObserverFunc) Observe(e Event) {
+    f(e)
+}
+
+// observerHolder 包装Observer，以便使用atomic.Pointer保存
+type observerHolder struct {
+    observer Observer
+    pattern  func(key string) string
+}
+
+// observers 全局安装的观察者
+var observers atomic.Pointer[observerHolder]
+
+// SetObserver 安装全局的观察者，传入nil表示移除，需要多个观察者时可以使用MultiObserver
+// pattern用于将缓存key转换为模式，为nil时使用DefaultKeyPattern
+func SetObserver(o Observer, pattern func(key string) string) {
+    if o == nil {
+        observers.Store(nil)
+        return
+    }
+    if pattern == nil {
+        pattern = DefaultKeyPattern
+    }
+    observers.Store(&observerHolder{observer: o, pattern: pattern})
+}
+
+// MultiObserver 将多个观察者组合为一个，按顺序通知
+func MultiObserver(list ...Observer) Observer {
+    return ObserverFunc(func(e Event) {
+        for _, o := range list {
+            if o != nil {
+                o.Observe(e)
+            }
+        }
+    })
+}
+
+// DefaultKeyPattern 默认的缓存key模式：按“:”分段，将纯数字以及较长的十六进制/UUID分段替换为“*”，
+// 命名空间版本号同样替换为“*”，如cachex:user:42 -> cachex:user:*，cachex:ns@3:item:7 -> cachex:ns@*:item:*
+func DefaultKeyPattern(key string) string {
+    segs := strings.Split(key, ":")
+    for i, seg := range segs {
+        if isIDSegment(seg) {
+            segs[i] = "*"
+            continue
+        }
+        if pos := strings.LastIndexByte(seg, '@'); pos >= 0 && isDigits(seg[pos+1:]) {
+            segs[i] = seg[:pos+1] + "*"
+        }
+    }
+    return strings.Join(segs, ":")
+}
+
+// isDigits 判断是否是非空的纯数字
+func isDigits(s string) bool {
+    if s == "" {
+        return false
+    }
+    for i := 0; i < len(s); i++ {
+        if s[i] < '0' || s[i] > '9' {
+            return false
+        }
+    }
+    return true
+}
+
+// isIDSegment 判断key的分段是否是ID：纯数字，或者长度不小于16的十六进制字符串（可以包含“-”）
+func isIDSegment(s string) bool {
+    if isDigits(s) {
+        return true
+    }
+    if len(s) < 16 {
+        return false
+    }
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' || c == '-') {
+            return false
+        }
+    }
+    return true
+}
+
+// emit 通知观察者，未安装观察者时直接返回
+func emit(t EventType, key string, d time.Duration, err error) {
+    notifyObserver(Event{Type: t, Key: key, Duration: d, Err: err})
+}
+
+// emitContext 通知观察者，事件中携带调用方的ctx
+func emitContext(ctx context.Context, t EventType, key string, d time.Duration, err error) {
+    notifyObserver(Event{Ctx: ctx, Type: t, Key: key, Duration: d, Err: err})
+}
+
+// emitHit 通知观察者命中缓存，source为命中的数据来源
+func emitHit(ctx context.Context, key string, source EventSource) {
+    notifyObserver(Event{Ctx: ctx, Type: EventHit, Source: source, Key: key})
+}
+
+// notifyObserver 补全事件的context以及key的模式后通知观察者，未安装观察者时直接返回
+func notifyObserver(e Event) {
+    h := observers.Load()
+    if h == nil {
+        return
+    }
+    if e.Ctx == nil {
+        e.Ctx = context.Background()
+    }
+    e.Pattern = h.pattern(e.Key)
+    h.observer.Observe(e)
+}
+
+// emit 通知观察者，事件中携带本次调用的ctx
+func (o *callOptions) emit(t EventType, key string, d time.Duration, err error) {
+    emitContext(o.ctx, t, key, d, err)
+}
+
+// CounterStats 某一类缓存key的统计数据
+type CounterStats struct {
+    Hits            int64         `json:"hits"`
+    LocalHits       int64         `json:"local_hits"` // 命中本地缓存的次数，包含在Hits中
+    Misses          int64         `json:"misses"`
+    Loads           int64         `json:"loads"`
+    LoadErrors      int64         `json:"load_errors"`
+    StoreErrors     int64         `json:"store_errors"`
+    LockContentions int64         `json:"lock_contentions"`
+    Evictions       int64         `json:"evictions"`
+    LoadTime        time.Duration `json:"load_time"` // 加载数据的总耗时
+}
+
+// HitRatio 返回命中率，没有任何访问时返回0
+func (s CounterStats) HitRatio() float64 {
+    total := s.Hits + s.Misses
+    if total == 0 {
+        return 0
+    }
+    return float64(s.Hits) / float64(total)
+}
+
+// Counters 内置的观察者，按缓存key的模式在内存中统计各类事件的次数
+// 实现了http.Handler，可以直接挂载到调试接口上，以json格式输出统计数据
+type Counters struct {
+    mu    sync.Mutex
+    stats map[string]*CounterStats
+}
+
+// NewCounters 创建一个内存计数器
+func NewCounters() *Counters {
+    return &Counters{
+        stats: make(map[string]*CounterStats),
+    }
+}
+
+// Observe 记录事件
+func (c *Counters) Observe(e Event) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    s, ok := c.stats[e.Pattern]
+    if !ok {
+        s = &CounterStats{}
+        c.stats[e.Pattern] = s
+    }
+    switch e.Type {
+    case EventHit:
+        s.Hits++
+        if e.Source == SourceLocal {
+            s.LocalHits++
+        }
+    case EventMiss:
+        s.Misses++
+    case EventLoad:
+        s.Loads++
+        s.LoadTime += e.Duration
+    case EventLoadError:
+        s.LoadErrors++
+        s.LoadTime += e.Duration
+    case EventStoreError:
+        s.StoreErrors++
+    case EventLockContention:
+        s.LockContentions++
+    case EventEviction:
+        s.Evictions++
+    }
+}
+
+// Snapshot 返回当前的统计数据，key为缓存key的模式
+func (c *Counters) Snapshot() map[string]CounterStats {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    rs := make(map[string]CounterStats, len(c.stats))
+    for pattern, s := range c.stats {
+        rs[pattern] = *s
+    }
+    return rs
+}
+
+// Reset 清空统计数据
+func (c *Counters) Reset() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.stats = make(map[string]*CounterStats)
+}
+
+// ServeHTTP 以json格式输出统计数据，按缓存key的模式排序
+func (c *Counters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    snapshot := c.Snapshot()
+    patterns := make([]string, 0, len(snapshot))
+    for pattern := range snapshot {
+        patterns = append(patterns, pattern)
+    }
+    sort.Strings(patterns)
+    type item struct {
+        Pattern  string  `json:"pattern"`
+        HitRatio float64 `json:"hit_ratio"`
+        CounterStats
+    }
+    items := make([]item, 0, len(patterns))
+    for _, pattern := range patterns {
+        s := snapshot[pattern]
+        items = append(items, item{Pattern: pattern, HitRatio: s.HitRatio(), CounterStats: s})
+    }
+    data, err := jsonkit.Marshal(items)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json; charset=utf-8")
+    _, _ = w.Write(data)
+}
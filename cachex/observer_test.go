@@ -0,0 +1,163 @@
+package cachex
+
+import (
+    "context"
+    "errors"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestDefaultKeyPattern(t *testing.T) {
+    cases := map[string]string{
+        "cachex:user:42":                              "cachex:user:*",
+        "cachex:ns@3:item:7":                          "cachex:ns@*:item:*",
+        "cachex:session:0f8e3c1a-9b2d-4e5f-8a7b-6c5d": "cachex:session:*",
+        "cachex:config:site":                          "cachex:config:site",
+    }
+    for key, pattern := range cases {
+        if got := DefaultKeyPattern(key); got != pattern {
+            t.Errorf("pattern of %s: expect %s, got %s", key, pattern, got)
+        }
+    }
+}
+
+func TestCountersObserver(t *testing.T) {
+    counters := NewCounters()
+    SetObserver(counters, nil)
+    defer SetObserver(nil, nil)
+
+    b := NewMemoryBackend(0)
+    bf := func() (interface{}, error) {
+        return "v", nil
+    }
+    var ret string
+    for i := 0; i < 3; i++ {
+        if err := BCall(&ret, b, "obs:1", 60, bf); err != nil {
+            t.Fatal(err)
+        }
+    }
+    bizErr := errors.New("biz error")
+    if err := BCall(&ret, b, "obs:2", 60, func() (interface{}, error) {
+        return nil, bizErr
+    }); err != bizErr {
+        t.Fatalf("expect biz error, got %v", err)
+    }
+    lock := NewLock(b, "obs:3", time.Second)
+    _ = lock.TryLock()
+    _ = NewLock(b, "obs:3", time.Second).TryLock()
+
+    s := counters.Snapshot()["cachex:obs:*"]
+    if s.Hits != 2 || s.Misses != 2 || s.Loads != 1 || s.LoadErrors != 1 || s.LockContentions != 1 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+    if ratio := s.HitRatio(); ratio != 0.5 {
+        t.Fatalf("unexpected hit ratio: %v", ratio)
+    }
+
+    w := httptest.NewRecorder()
+    counters.ServeHTTP(w, httptest.NewRequest("GET", "/debug/cachex", nil))
+    if !strings.Contains(w.Body.String(), `"pattern":"cachex:obs:*"`) {
+        t.Fatalf("unexpected response: %s", w.Body.String())
+    }
+    counters.Reset()
+    if len(counters.Snapshot()) != 0 {
+        t.Fatal("counters should be reset")
+    }
+}
+
+func TestEvictionEvent(t *testing.T) {
+    counters := NewCounters()
+    SetObserver(counters, nil)
+    defer SetObserver(nil, nil)
+
+    b := NewMemoryBackend(2)
+    for _, key := range []string{"evict:1", "evict:2", "evict:3"} {
+        _ = b.Set(key, []byte("v"), 0)
+    }
+    if n := counters.Snapshot()["evict:*"].Evictions; n != 1 {
+        t.Fatalf("expect 1 eviction, got %d", n)
+    }
+}
+
+func TestEvictionEventReentrant(t *testing.T) {
+    b := NewMemoryBackend(1)
+    evicted := make(chan bool, 1)
+    // 观察者在淘汰事件中访问缓存时不能死锁
+    SetObserver(ObserverFunc(func(e Event) {
+        if e.Type == EventEviction {
+            ok, _ := b.Exists(e.Key)
+            evicted <- ok
+        }
+    }), nil)
+    defer SetObserver(nil, nil)
+
+    _ = b.Set("reentrant:1", []byte("v"), 0)
+    _ = b.Set("reentrant:2", []byte("v"), 0)
+    select {
+    case ok := <-evicted:
+        if ok {
+            t.Fatal("evicted key should not exist")
+        }
+    case <-time.After(time.Second):
+        t.Fatal("eviction event not received")
+    }
+}
+
+func TestEventContextAndSource(t *testing.T) {
+    type traceKey struct{}
+    var events []Event
+    SetObserver(ObserverFunc(func(e Event) {
+        events = append(events, e)
+    }), nil)
+    defer SetObserver(nil, nil)
+    lc := NewLocalCache(nil, nil)
+    SetLocalCache(lc)
+    defer func() {
+        SetLocalCache(nil)
+        lc.Close()
+    }()
+
+    b := NewMemoryBackend(0)
+    ctx := context.WithValue(context.Background(), traceKey{}, "trace-1")
+    bf := func(ctx context.Context) (interface{}, error) {
+        return "v", nil
+    }
+    var ret string
+    // 第一次未命中并加载，第二次命中本地缓存，不使用本地缓存时命中二级缓存
+    _ = BCallContext(ctx, &ret, b, "trace:1", 60, bf, WithLocalCache())
+    _ = BCallContext(ctx, &ret, b, "trace:1", 60, bf, WithLocalCache())
+    _ = BCallContext(ctx, &ret, b, "trace:1", 60, bf)
+    expects := []struct {
+        t      EventType
+        source EventSource
+    }{
+        {EventMiss, 0},
+        {EventLoad, 0},
+        {EventHit, SourceLocal},
+        {EventHit, SourceBackend},
+    }
+    if len(events) != len(expects) {
+        t.Fatalf("unexpected events: %+v", events)
+    }
+    for i, e := range events {
+        if e.Type != expects[i].t || e.Source != expects[i].source {
+            t.Fatalf("unexpected event %d: %+v", i, e)
+        }
+        if e.Ctx.Value(traceKey{}) != "trace-1" {
+            t.Fatalf("event %d should carry the caller context", i)
+        }
+    }
+
+    // Get[T]同样通知命中和未命中，未传入context时使用context.Background()
+    events = nil
+    _, _ = Get[string](b, "trace:1")
+    _, _ = Get[string](b, "trace:2")
+    if len(events) != 2 || events[0].Type != EventHit || events[0].Source != SourceBackend || events[1].Type != EventMiss {
+        t.Fatalf("unexpected events: %+v", events)
+    }
+    if events[0].Ctx == nil {
+        t.Fatal("event context should not be nil")
+    }
+}
@@ -0,0 +1,134 @@
+package cachex
+
+import (
+    "context"
+    "time"
+)
+
+// 默认的分布式加载锁参数
+const (
+    defaultLoadLockExpire = 5                      // 加载锁的默认过期时间，单位：秒
+    defaultLoadLockWait   = time.Second * 3        // 未获得锁时等待其它进程加载结果的最长时间
+    loadLockPollInterval  = time.Millisecond * 50  // 等待期间轮询缓存的间隔
+    loadLockKeySuffix     = ":loadlock"            // 加载锁key的后缀
+)
+
+// callOptions Call系列方法的可选参数
+type callOptions struct {
+    singleflight   bool            // 是否合并进程内同一个key的并发加载
+    loadLock       bool            // 是否使用分布式锁在多个进程间协调加载
+    loadLockExpire int64           // 加载锁的过期时间，单位：秒
+    loadLockWait   time.Duration   // 未获得锁时等待的最长时间
+    notFoundExpire int64           // 空结果的缓存时间，单位：秒，小于等于0表示不缓存空结果
+    softExpire     int64           // 软过期时间，单位：秒，超过后返回旧数据并刷新，小于等于0表示不启用
+    earlyBeta      float64         // 提前刷新系数，大于0时按概率提前刷新
+    jitter         float64         // 过期时间随机缩短的最大比例，取值范围(0, 1)
+    refreshBackend Backend         // 后台刷新使用的Backend，需支持并发使用
+    codec          Codec           // 写入缓存使用的编码格式，为nil时使用全局默认编码格式
+    tags           []string        // 写入缓存时关联的标签
+    local          bool            // 是否使用本地缓存
+    ctx            context.Context // 本次调用的context，为nil表示不限制
+    bizCtx         BizFuncContext  // 接收context的业务方法，设置后代替BizFunc被调用
+    bloom          *BloomFilter    // 缓存未命中时用于拦截一定不存在的数据
+    bloomItem      string          // 在布隆过滤器中检查的元素
+    loaded         func() bool     // 返回true表示业务方法的结果已经直接交给调用方，不需要再解码缓存数据
+}
+
+// CallOption 设置Call系列方法的可选参数
+type CallOption func(o *callOptions)
+
+// newCallOptions 根据传入的option生成参数
+func newCallOptions(opts []CallOption) *callOptions {
+    o := &callOptions{}
+    for _, opt := range opts {
+        if opt != nil {
+            opt(o)
+        }
+    }
+    return o
+}
+
+// withProviderRefresh 为Pxxx系列方法添加基于provider的后台刷新Backend，调用方指定的优先
+func withProviderRefresh(provider Provider, opts []CallOption) []CallOption {
+    return append([]CallOption{WithRefreshBackend(NewProviderBackend(provider))}, opts...)
+}
+
+// WithSingleflight 缓存未命中时，合并进程内对同一个key的并发调用，只执行一次BizFunc，其它调用等待并共享结果（包括错误）
+func WithSingleflight() CallOption {
+    return func(o *callOptions) {
+        o.singleflight = true
+    }
+}
+
+// WithLoadLock 缓存未命中时，使用一个短期的分布式锁在多个进程间协调加载（会同时启用进程内合并）
+// expire - 锁的过期时间，单位：秒，小于等于0时使用默认值
+// wait - 未获得锁时等待其它进程写入缓存的最长时间，超时后自行调用BizFunc，小于等于0时使用默认值
+func WithLoadLock(expire int64, wait time.Duration) CallOption {
+    return func(o *callOptions) {
+        if expire <= 0 {
+            expire = defaultLoadLockExpire
+        }
+        if wait <= 0 {
+            wait = defaultLoadLockWait
+        }
+        o.singleflight = true
+        o.loadLock = true
+        o.loadLockExpire = expire
+        o.loadLockWait = wait
+    }
+}
+
+// WithNotFoundTTL 缓存空结果（BizFunc返回nil或者ErrNotFound），防止不存在的数据每次都穿透到数据库
+// expire - 空结果的缓存时间，单位：秒，一般应小于正常数据的缓存时间，小于等于0表示不缓存
+// 命中空结果缓存时，Call系列方法会返回ErrNotFound
+func WithNotFoundTTL(expire int64) CallOption {
+    return func(o *callOptions) {
+        o.notFoundExpire = expire
+    }
+}
+
+// WithStaleWhileRevalidate 启用软过期，缓存写入后超过softExpire秒即视为过期但仍可使用：
+// 此时直接返回旧数据，同时由一个调用方负责调用BizFunc刷新缓存，Call的expire参数则作为硬过期时间
+// 当Backend可以并发使用（如MemoryBackend、NewProviderBackend以及PCall系列方法）或者通过WithRefreshBackend指定了Backend时在后台刷新，
+// 否则由获得刷新权的调用方同步刷新
+func WithStaleWhileRevalidate(softExpire int64) CallOption {
+    return func(o *callOptions) {
+        o.softExpire = softExpire
+    }
+}
+
+// WithEarlyRefresh 启用概率性提前刷新，越接近过期时间、BizFunc耗时越长，提前刷新的概率越大，
+// beta为调节系数，一般取1，值越大越倾向于提前刷新
+func WithEarlyRefresh(beta float64) CallOption {
+    return func(o *callOptions) {
+        o.earlyBeta = beta
+    }
+}
+
+// WithExpireJitter 对缓存的过期时间进行随机缩短，缩短的最大比例为ratio，避免同一时间写入的缓存同时过期
+func WithExpireJitter(ratio float64) CallOption {
+    return func(o *callOptions) {
+        if ratio < 0 {
+            ratio = 0
+        }
+        if ratio >= 1 {
+            ratio = 0.99
+        }
+        o.jitter = ratio
+    }
+}
+
+// WithRefreshBackend 指定后台刷新使用的Backend，此Backend需要支持在多个goroutine中同时使用
+func WithRefreshBackend(b Backend) CallOption {
+    return func(o *callOptions) {
+        o.refreshBackend = b
+    }
+}
+
+// WithCodec 指定写入缓存使用的编码格式，读取时会根据数据中的编码格式标记自动选择解码方式
+// 同样适用于Store系列方法
+func WithCodec(c Codec) CallOption {
+    return func(o *callOptions) {
+        o.codec = c
+    }
+}
@@ -0,0 +1,80 @@
+package cachex
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "sync"
+
+    "github.com/whencome/goutil"
+)
+
+// errFlightPanic 合并调用的执行方发生panic时，等待方收到的错误
+var errFlightPanic = errors.New("cachex: coalesced call panicked")
+
+// flightCall 一次正在进行中的调用
+type flightCall struct {
+    done chan struct{}
+    data []byte
+    err  error
+}
+
+// flightGroup 合并同一个key的并发调用，同一时刻只有一个调用真正执行，其它调用等待并共享其结果
+type flightGroup struct {
+    mu    sync.Mutex
+    calls map[string]*flightCall
+}
+
+// callGroup 用于合并Call在缓存未命中时的并发调用
+var callGroup = &flightGroup{}
+
+// Do 执行fn，如果相同key的调用正在进行中，则等待其完成并返回相同的结果
+func (g *flightGroup) Do(key string, fn func() ([]byte, error)) ([]byte, error) {
+    return g.DoContext(context.Background(), key, fn)
+}
+
+// DoContext 与Do相同，但等待其它调用的结果时，ctx结束后立即返回ctx的错误
+func (g *flightGroup) DoContext(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, error) {
+    g.mu.Lock()
+    if g.calls == nil {
+        g.calls = make(map[string]*flightCall)
+    }
+    if c, ok := g.calls[key]; ok {
+        g.mu.Unlock()
+        select {
+        case <-c.done:
+            return c.data, c.err
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+    c := &flightCall{done: make(chan struct{})}
+    g.calls[key] = c
+    g.mu.Unlock()
+
+    // 确保fn发生panic时等待方也能够退出
+    c.err = errFlightPanic
+    defer func() {
+        g.mu.Lock()
+        delete(g.calls, key)
+        g.mu.Unlock()
+        close(c.done)
+    }()
+    c.data, c.err = fn()
+    return c.data, c.err
+}
+
+// isContextError 判断是否是context结束导致的错误
+func isContextError(err error) bool {
+    return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// randomToken 生成一个随机字符串，用于标识锁的持有者
+func randomToken() string {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return goutil.RandString(32)
+    }
+    return hex.EncodeToString(buf)
+}
@@ -0,0 +1,67 @@
+package cachex
+
+import (
+    "errors"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestBCallSingleflight(t *testing.T) {
+    b := NewMemoryBackend(0)
+    var calls int32
+    bf := func() (interface{}, error) {
+        atomic.AddInt32(&calls, 1)
+        time.Sleep(time.Millisecond * 100)
+        return "hot", nil
+    }
+    wg := sync.WaitGroup{}
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            var ret string
+            if err := BCall(&ret, b, "sf:hot", 60, bf, WithSingleflight()); err != nil {
+                t.Error(err)
+                return
+            }
+            if ret != "hot" {
+                t.Errorf("unexpected result: %s", ret)
+            }
+        }()
+    }
+    wg.Wait()
+    if calls != 1 {
+        t.Fatalf("business func should be called once, got %d", calls)
+    }
+}
+
+func TestBCallSingleflightError(t *testing.T) {
+    b := NewMemoryBackend(0)
+    bizErr := errors.New("biz error")
+    var calls int32
+    bf := func() (interface{}, error) {
+        atomic.AddInt32(&calls, 1)
+        time.Sleep(time.Millisecond * 100)
+        return nil, bizErr
+    }
+    wg := sync.WaitGroup{}
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            var ret string
+            if err := BCall(&ret, b, "sf:err", 60, bf, WithLoadLock(1, time.Second)); err != bizErr {
+                t.Errorf("expect biz error, got %v", err)
+            }
+        }()
+    }
+    wg.Wait()
+    if calls != 1 {
+        t.Fatalf("business func should be called once, got %d", calls)
+    }
+    if ok, _ := b.Exists(getCacheKey("sf:err") + loadLockKeySuffix); ok {
+        t.Fatal("load lock should be released")
+    }
+}
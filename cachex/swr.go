@@ -0,0 +1,182 @@
+package cachex
+
+import (
+    "bytes"
+    "math"
+    "math/rand"
+    "strconv"
+    "sync"
+    "time"
+)
+
+const (
+    refreshLockKeySuffix = ":refreshlock" // 刷新锁key的后缀
+)
+
+// entryPrefix 带有元数据的缓存数据前缀，格式为：前缀 + 软过期时间:硬过期时间:加载耗时: + 数据，时间单位均为毫秒
+var entryPrefix = []byte("\x00cachex:entry:")
+
+// refreshing 记录进程内正在刷新的key，避免重复刷新
+var refreshing sync.Map
+
+// entryMeta 缓存数据的元数据
+type entryMeta struct {
+    softExpireAt int64 // 软过期时间，超过此时间数据仍然可用，但需要刷新，为0表示未设置
+    hardExpireAt int64 // 硬过期时间，即缓存实际过期的时间，为0表示永不过期
+    delta        int64 // 加载数据的耗时，用于计算提前刷新的概率
+}
+
+// wrapEntry 为数据添加元数据
+func wrapEntry(data []byte, meta *entryMeta) []byte {
+    buf := bytes.NewBuffer(make([]byte, 0, len(entryPrefix)+len(data)+48))
+    buf.Write(entryPrefix)
+    buf.WriteString(strconv.FormatInt(meta.softExpireAt, 10))
+    buf.WriteByte(':')
+    buf.WriteString(strconv.FormatInt(meta.hardExpireAt, 10))
+    buf.WriteByte(':')
+    buf.WriteString(strconv.FormatInt(meta.delta, 10))
+    buf.WriteByte(':')
+    buf.Write(data)
+    return buf.Bytes()
+}
+
+// unwrapEntry 解析带有元数据的缓存数据，如果数据不带元数据，则原样返回，元数据为nil
+func unwrapEntry(data []byte) ([]byte, *entryMeta) {
+    if !bytes.HasPrefix(data, entryPrefix) {
+        return data, nil
+    }
+    rest := data[len(entryPrefix):]
+    fields := make([]int64, 3)
+    for i := range fields {
+        pos := bytes.IndexByte(rest, ':')
+        if pos < 0 {
+            return data, nil
+        }
+        v, err := strconv.ParseInt(string(rest[:pos]), 10, 64)
+        if err != nil {
+            return data, nil
+        }
+        fields[i] = v
+        rest = rest[pos+1:]
+    }
+    return rest, &entryMeta{
+        softExpireAt: fields[0],
+        hardExpireAt: fields[1],
+        delta:        fields[2],
+    }
+}
+
+// needRefresh 判断缓存数据是否需要刷新
+func (o *callOptions) needRefresh(meta *entryMeta, now time.Time) bool {
+    nowMs := now.UnixMilli()
+    if o.softExpire > 0 && meta.softExpireAt > 0 && nowMs >= meta.softExpireAt {
+        return true
+    }
+    if o.earlyBeta > 0 {
+        target := meta.softExpireAt
+        if target <= 0 {
+            target = meta.hardExpireAt
+        }
+        if target <= 0 {
+            return false
+        }
+        // 参考XFetch算法，越接近过期时间、加载耗时越长，提前刷新的概率越大
+        gap := -float64(meta.delta) * o.earlyBeta * math.Log(1-rand.Float64())
+        return float64(nowMs)+gap >= float64(target)
+    }
+    return false
+}
+
+// jitterExpire 对过期时间进行随机缩短，避免同一时间写入的缓存同时过期
+func (o *callOptions) jitterExpire(expire int64) int64 {
+    if o.jitter <= 0 || expire <= 1 {
+        return expire
+    }
+    v := expire - int64(float64(expire)*o.jitter*rand.Float64())
+    if v < 1 {
+        v = 1
+    }
+    return v
+}
+
+// encodeEntry 生成写入缓存的数据，需要时附加元数据，并返回实际使用的过期时间
+func (o *callOptions) encodeEntry(data []byte, expire int64, delta time.Duration) ([]byte, int64) {
+    ttl := o.jitterExpire(expire)
+    if o.softExpire <= 0 && o.earlyBeta <= 0 {
+        return data, ttl
+    }
+    now := time.Now()
+    meta := &entryMeta{delta: delta.Milliseconds()}
+    if ttl > 0 {
+        meta.hardExpireAt = now.Add(time.Duration(ttl) * time.Second).UnixMilli()
+    }
+    if o.softExpire > 0 {
+        softExpire := o.softExpire
+        if ttl > 0 && expire > 0 {
+            // 软过期时间与硬过期时间按相同比例缩短
+            softExpire = softExpire * ttl / expire
+        }
+        if softExpire < 1 {
+            softExpire = 1
+        }
+        meta.softExpireAt = now.Add(time.Duration(softExpire) * time.Second).UnixMilli()
+    }
+    return wrapEntry(data, meta), ttl
+}
+
+// revalidate 检查缓存数据是否需要刷新，需要刷新时由一个调用方负责刷新
+// 如果有可以异步使用的Backend，则在后台刷新，当前调用直接使用旧数据；否则由获得刷新权的调用方同步刷新并返回新数据
+func revalidate(b Backend, cacheKey string, expire int64, bf BizFunc, o *callOptions, meta *entryMeta) []byte {
+    if !o.needRefresh(meta, time.Now()) {
+        return nil
+    }
+    asyncBackend := o.refreshBackend
+    if asyncBackend == nil {
+        if _, ok := b.(concurrentBackend); ok {
+            asyncBackend = b
+        }
+    }
+    if asyncBackend != nil {
+        if _, loaded := refreshing.LoadOrStore(cacheKey, struct{}{}); loaded {
+            return nil
+        }
+        // 后台刷新不受调用方context取消的影响
+        o = o.detach()
+        asyncBackend = backendWithContext(asyncBackend, o.ctx)
+        go func() {
+            defer refreshing.Delete(cacheKey)
+            // 后台刷新失败不影响调用方，忽略panic
+            defer func() {
+                _ = recover()
+            }()
+            _, _ = refresh(asyncBackend, cacheKey, expire, bf, o)
+        }()
+        return nil
+    }
+    if _, loaded := refreshing.LoadOrStore(cacheKey, struct{}{}); loaded {
+        return nil
+    }
+    defer refreshing.Delete(cacheKey)
+    data, ok := refresh(b, cacheKey, expire, bf, o)
+    if !ok {
+        return nil
+    }
+    return data
+}
+
+// refresh 获取分布式刷新锁后重新加载数据，未获得锁时说明其它进程正在刷新
+func refresh(b Backend, cacheKey string, expire int64, bf BizFunc, o *callOptions) ([]byte, bool) {
+    lockKey := cacheKey + refreshLockKeySuffix
+    token := randomToken()
+    locked, err := acquireToken(b, lockKey, token, defaultLoadLockExpire)
+    if err != nil || !locked {
+        return nil, false
+    }
+    // 仅释放自己持有的锁
+    defer releaseToken(b, lockKey, token)
+    data, err := doLoad(b, cacheKey, expire, bf, o)
+    if err != nil || data == nil {
+        return nil, false
+    }
+    return data, true
+}
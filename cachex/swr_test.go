@@ -0,0 +1,56 @@
+package cachex
+
+import (
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestBCallStaleWhileRevalidate(t *testing.T) {
+    b := NewMemoryBackend(0)
+    var version int32
+    bf := func() (interface{}, error) {
+        return atomic.AddInt32(&version, 1), nil
+    }
+    call := func() int32 {
+        var ret int32
+        if err := BCall(&ret, b, "swr:v", 60, bf, WithStaleWhileRevalidate(1)); err != nil {
+            t.Fatal(err)
+        }
+        return ret
+    }
+    if v := call(); v != 1 {
+        t.Fatalf("unexpected version: %d", v)
+    }
+    time.Sleep(time.Millisecond * 1100)
+    // 软过期后直接返回旧数据，并在后台刷新
+    if v := call(); v != 1 {
+        t.Fatalf("expect stale version 1, got %d", v)
+    }
+    time.Sleep(time.Millisecond * 100)
+    if v := call(); v != 2 {
+        t.Fatalf("expect refreshed version 2, got %d", v)
+    }
+    // 未指定软过期的调用方也能正常读取带有元数据的缓存
+    var ret int32
+    if err := BFetch(&ret, b, "swr:v"); err != nil || ret != 2 {
+        t.Fatalf("unexpected fetch result: %d, %v", ret, err)
+    }
+}
+
+func TestEntryEncode(t *testing.T) {
+    o := newCallOptions([]CallOption{WithStaleWhileRevalidate(10), WithExpireJitter(0.5)})
+    for i := 0; i < 100; i++ {
+        data, ttl := o.encodeEntry([]byte(`"v"`), 100, time.Millisecond*20)
+        if ttl < 50 || ttl > 100 {
+            t.Fatalf("unexpected ttl: %d", ttl)
+        }
+        payload, meta := unwrapEntry(data)
+        if string(payload) != `"v"` || meta == nil || meta.delta != 20 {
+            t.Fatalf("unexpected entry: %s, %+v", payload, meta)
+        }
+        if meta.softExpireAt > meta.hardExpireAt {
+            t.Fatalf("soft expire should not exceed hard expire: %+v", meta)
+        }
+    }
+}
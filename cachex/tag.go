@@ -0,0 +1,162 @@
+package cachex
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+
+    "github.com/gomodule/redigo/redis"
+)
+
+const (
+    // reservedKeySeparator 内部key与前缀之间的分隔符，业务key与前缀之间使用“:”分隔，因此不会与业务key冲突
+    // 未设置前缀时，业务key不能以此分隔符开头
+    reservedKeySeparator = "|"
+    tagKeyKind           = "tag" // 标签集合key的类型
+    namespaceKeyKind     = "ns"  // 命名空间版本号key的类型
+)
+
+// ErrTagUnsupported Backend未实现TagBackend接口
+var ErrTagUnsupported = errors.New("cachex: backend does not support tags")
+
+// WithTags 为写入的缓存关联标签（如user:42、tenant:7），之后可以通过InvalidateTags清除关联了标签的所有缓存
+// 适用于Call以及Store系列方法
+func WithTags(tags ...string) CallOption {
+    return func(o *callOptions) {
+        o.tags = append(o.tags, tags...)
+    }
+}
+
+// getReservedKey 获取内部使用的完整key，格式为：前缀|类型|名称
+func getReservedKey(kind, name string) string {
+    return cacheKeyPrefix + reservedKeySeparator + kind + reservedKeySeparator + name
+}
+
+// getTagKey 获取标签集合的完整key
+func getTagKey(tag string) string {
+    return getReservedKey(tagKeyKind, tag)
+}
+
+// checkTags 检查Backend是否支持标签，需要在写入缓存之前调用
+func checkTags(b Backend, tags []string) error {
+    if len(tags) == 0 {
+        return nil
+    }
+    if _, ok := b.(TagBackend); !ok {
+        return ErrTagUnsupported
+    }
+    return nil
+}
+
+// addTags 将完整的缓存key关联到标签上
+// 关联失败时删除已经写入的缓存，避免留下无法通过标签清除的数据，并通知观察者写入失败
+func addTags(b Backend, tags []string, expire int64, fullKeys ...string) error {
+    if len(tags) == 0 || len(fullKeys) == 0 {
+        return nil
+    }
+    if err := checkTags(b, tags); err != nil {
+        return err
+    }
+    tb := b.(TagBackend)
+    for _, tag := range tags {
+        if err := tb.TagAdd(getTagKey(tag), expire, fullKeys...); err != nil {
+            _ = b.Del(fullKeys...)
+            for _, key := range fullKeys {
+                emit(EventStoreError, key, 0, err)
+            }
+            return err
+        }
+    }
+    return nil
+}
+
+// InvalidateTags 清除关联了任意一个标签的所有缓存
+func InvalidateTags(rds redis.Conn, tags ...string) error {
+    return BInvalidateTags(NewRedisBackend(rds), tags...)
+}
+
+// PInvalidateTags 清除关联了任意一个标签的所有缓存
+func PInvalidateTags(provider Provider, tags ...string) error {
+    rds := provider.Redis()
+    defer rds.Close()
+    return InvalidateTags(rds, tags...)
+}
+
+// BInvalidateTags 清除关联了任意一个标签的所有缓存
+func BInvalidateTags(b Backend, tags ...string) error {
+    tb, ok := b.(TagBackend)
+    if !ok {
+        return ErrTagUnsupported
+    }
+    for _, tag := range tags {
+        members, err := tb.TagPop(getTagKey(tag))
+        if err != nil {
+            return err
+        }
+        if len(members) == 0 {
+            continue
+        }
+        if err = b.Del(members...); err != nil {
+            return err
+        }
+        invalidateLocal(members...)
+    }
+    return nil
+}
+
+// getNamespaceKey 获取命名空间版本号的完整key
+func getNamespaceKey(ns string) string {
+    return getReservedKey(namespaceKeyKind, ns)
+}
+
+// NamespaceKey 返回命名空间中的缓存key，key中包含命名空间当前的版本号
+// 调用InvalidateNamespace递增版本号后，之前生成的key将不再被使用，旧数据等待自然过期，无需扫描删除
+func NamespaceKey(rds redis.Conn, ns string, cacheKey string) (string, error) {
+    return BNamespaceKey(NewRedisBackend(rds), ns, cacheKey)
+}
+
+// PNamespaceKey 返回命名空间中的缓存key
+func PNamespaceKey(provider Provider, ns string, cacheKey string) (string, error) {
+    rds := provider.Redis()
+    defer rds.Close()
+    return NamespaceKey(rds, ns, cacheKey)
+}
+
+// BNamespaceKey 返回命名空间中的缓存key
+func BNamespaceKey(b Backend, ns string, cacheKey string) (string, error) {
+    var version int64
+    data, err := b.Get(getNamespaceKey(ns))
+    if err != nil && err != ErrNil {
+        return "", err
+    }
+    if err == nil {
+        version, err = strconv.ParseInt(string(data), 10, 64)
+        if err != nil {
+            return "", ErrNotInteger
+        }
+    }
+    // 保留无前缀标记
+    prefix := ""
+    if strings.HasPrefix(cacheKey, NoPrefixKey) {
+        prefix = NoPrefixKey
+        cacheKey = cacheKey[len(NoPrefixKey):]
+    }
+    return prefix + ns + "@" + strconv.FormatInt(version, 10) + ":" + cacheKey, nil
+}
+
+// InvalidateNamespace 递增命名空间的版本号，使命名空间中的所有缓存失效，返回新的版本号
+func InvalidateNamespace(rds redis.Conn, ns string) (int64, error) {
+    return BInvalidateNamespace(NewRedisBackend(rds), ns)
+}
+
+// PInvalidateNamespace 递增命名空间的版本号，使命名空间中的所有缓存失效
+func PInvalidateNamespace(provider Provider, ns string) (int64, error) {
+    rds := provider.Redis()
+    defer rds.Close()
+    return InvalidateNamespace(rds, ns)
+}
+
+// BInvalidateNamespace 递增命名空间的版本号，使命名空间中的所有缓存失效
+func BInvalidateNamespace(b Backend, ns string) (int64, error) {
+    return b.IncrBy(getNamespaceKey(ns), 1)
+}
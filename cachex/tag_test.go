@@ -0,0 +1,128 @@
+package cachex
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestInvalidateTags(t *testing.T) {
+    b := NewMemoryBackend(0)
+    bf := func() (interface{}, error) {
+        return "profile", nil
+    }
+    var ret string
+    _ = BCall(&ret, b, "user:42:profile", 60, bf, WithTags("user:42", "tenant:7"))
+    _ = BStore(b, "user:42:orders", 60, []int{1, 2}, WithTags("user:42"))
+    _ = BStore(b, "user:43:orders", 60, []int{3}, WithTags("user:43", "tenant:7"))
+
+    if err := BInvalidateTags(b, "user:42"); err != nil {
+        t.Fatal(err)
+    }
+    for _, k := range []string{"user:42:profile", "user:42:orders"} {
+        if ok, _ := BExists(b, k); ok {
+            t.Fatalf("%s should be invalidated", k)
+        }
+    }
+    if ok, _ := BExists(b, "user:43:orders"); !ok {
+        t.Fatal("user:43:orders should be kept")
+    }
+    _ = BInvalidateTags(b, "tenant:7")
+    if ok, _ := BExists(b, "user:43:orders"); ok {
+        t.Fatal("user:43:orders should be invalidated")
+    }
+}
+
+func TestNamespace(t *testing.T) {
+    b := NewMemoryBackend(0)
+    k1, err := BNamespaceKey(b, "tenant:7", "config")
+    if err != nil {
+        t.Fatal(err)
+    }
+    _ = BStore(b, k1, 60, "v1")
+    if _, err = BInvalidateNamespace(b, "tenant:7"); err != nil {
+        t.Fatal(err)
+    }
+    k2, _ := BNamespaceKey(b, "tenant:7", "config")
+    if k1 == k2 {
+        t.Fatalf("namespace key should change after invalidation: %s", k1)
+    }
+    var ret string
+    _ = BFetch(&ret, b, k2)
+    if ret != "" {
+        t.Fatalf("stale value should not be visible: %s", ret)
+    }
+}
+
+// failingTagBackend 关联标签总是失败的Backend
+type failingTagBackend struct {
+    *MemoryBackend
+}
+
+var errTagAdd = errors.New("tag add failed")
+
+func (f failingTagBackend) TagAdd(tagKey string, expire int64, members ...string) error {
+    return errTagAdd
+}
+
+func TestTagsUnsupported(t *testing.T) {
+    // 不支持标签时不写入缓存，也不调用业务方法
+    b := plainBackend{NewMemoryBackend(0)}
+    if err := BStore(b, "plain:1", 60, "v", WithTags("t")); err != ErrTagUnsupported {
+        t.Fatalf("expect ErrTagUnsupported, got %v", err)
+    }
+    if ok, _ := BExists(b, "plain:1"); ok {
+        t.Fatal("value should not be stored without tag support")
+    }
+    calls := 0
+    var ret string
+    err := BCall(&ret, b, "plain:2", 60, func() (interface{}, error) {
+        calls++
+        return "v", nil
+    }, WithTags("t"))
+    if err != ErrTagUnsupported || calls != 0 {
+        t.Fatalf("expect ErrTagUnsupported without loading, got %v, %d calls", err, calls)
+    }
+
+    // 关联标签失败时两条路径都删除写入的缓存
+    fb := failingTagBackend{NewMemoryBackend(0)}
+    if err = BStore(fb, "fail:1", 60, "v", WithTags("t")); err != errTagAdd {
+        t.Fatalf("expect tag error, got %v", err)
+    }
+    if err = BCall(&ret, fb, "fail:2", 60, func() (interface{}, error) {
+        return "v", nil
+    }, WithTags("t")); err != nil || ret != "v" {
+        t.Fatalf("load should succeed, got %s, %v", ret, err)
+    }
+    for _, k := range []string{"fail:1", "fail:2"} {
+        if ok, _ := BExists(fb, k); ok {
+            t.Fatalf("%s should be removed after tag failure", k)
+        }
+    }
+}
+
+func TestReservedKeys(t *testing.T) {
+    b := NewMemoryBackend(0)
+    _ = BStore(b, "item:1", 60, "v", WithTags("x"))
+    // 与标签集合以及命名空间同名的业务key不会冲突
+    if err := BStore(b, "tag:x", 60, "user value"); err != nil {
+        t.Fatal(err)
+    }
+    if err := BStore(b, "ns:x", 60, "user value"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := BInvalidateNamespace(b, "x"); err != nil {
+        t.Fatal(err)
+    }
+    if err := BInvalidateTags(b, "x"); err != nil {
+        t.Fatal(err)
+    }
+    if ok, _ := BExists(b, "item:1"); ok {
+        t.Fatal("item:1 should be invalidated")
+    }
+    for _, k := range []string{"tag:x", "ns:x"} {
+        var ret string
+        if err := BFetch(&ret, b, k); err != nil || ret != "user value" {
+            t.Fatalf("user key %s should be kept, got %s, %v", k, ret, err)
+        }
+    }
+}
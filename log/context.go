@@ -0,0 +1,146 @@
+package log
+
+import (
+    "context"
+    "sync"
+)
+
+// loggerKey context中保存logger的key
+type loggerKey struct{}
+
+// ContextExtractor 从context中提取需要自动记录的字段，如trace id、request id等
+type ContextExtractor func(ctx context.Context) Fields
+
+var (
+    extractorMu sync.RWMutex
+    extractors  []ContextExtractor
+)
+
+// RegisterContextExtractor 注册context字段提取方法，通过FromContext以及XxxContext系列方法记录日志时会自动添加提取的字段
+func RegisterContextExtractor(e ContextExtractor) {
+    if e == nil {
+        return
+    }
+    extractorMu.Lock()
+    extractors = append(extractors, e)
+    extractorMu.Unlock()
+}
+
+// resetContextExtractors 清除所有已注册的提取方法，用于测试
+func resetContextExtractors() {
+    extractorMu.Lock()
+    extractors = nil
+    extractorMu.Unlock()
+}
+
+// ContextValueExtractor 创建一个将ctx.Value(key)记录为field字段的提取方法，值不存在时不记录
+func ContextValueExtractor(field string, key interface{}) ContextExtractor {
+    return func(ctx context.Context) Fields {
+        v := ctx.Value(key)
+        if v == nil {
+            return nil
+        }
+        return Fields{field: v}
+    }
+}
+
+// extractFields 使用已注册的提取方法从ctx中提取字段
+func extractFields(ctx context.Context) Fields {
+    extractorMu.RLock()
+    defer extractorMu.RUnlock()
+    var fields Fields
+    for _, e := range extractors {
+        for k, v := range e(ctx) {
+            if fields == nil {
+                fields = Fields{}
+            }
+            fields[k] = v
+        }
+    }
+    return fields
+}
+
+// NewContext 将logger保存到ctx中，之后可以通过FromContext获取
+func NewContext(ctx context.Context, logger Logger) context.Context {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// ContextWithFields 在ctx中的logger（没有时使用默认logger）上添加字段并保存到新的ctx中，用于在调用链中逐层补充字段
+func ContextWithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+    return NewContext(ctx, contextLogger(ctx).WithFields(fields))
+}
+
+// contextLogger 返回ctx中保存的logger，没有时返回默认logger
+func contextLogger(ctx context.Context) Logger {
+    if ctx != nil {
+        if logger, ok := ctx.Value(loggerKey{}).(Logger); ok && logger != nil {
+            return logger
+        }
+    }
+    return stdLogger
+}
+
+// FromContext 返回ctx中保存的logger，没有时返回默认logger，并添加通过已注册的提取方法从ctx中提取的字段
+func FromContext(ctx context.Context) Logger {
+    logger := contextLogger(ctx)
+    if ctx == nil {
+        return logger
+    }
+    if fields := extractFields(ctx); len(fields) > 0 {
+        logger = logger.WithFields(fields)
+    }
+    return logger
+}
+
+// Context Print family functions
+func DebugContext(ctx context.Context, args ...interface{}) {
+    WithStack(FromContext(ctx)).Debug(args...)
+}
+
+func InfoContext(ctx context.Context, args ...interface{}) {
+    WithStack(FromContext(ctx)).Info(args...)
+}
+
+func WarnContext(ctx context.Context, args ...interface{}) {
+    WithStack(FromContext(ctx)).Warn(args...)
+}
+
+func ErrorContext(ctx context.Context, args ...interface{}) {
+    WithStack(FromContext(ctx)).Error(args...)
+}
+
+func FatalContext(ctx context.Context, args ...interface{}) {
+    WithStack(FromContext(ctx)).Fatal(args...)
+}
+
+func PanicContext(ctx context.Context, args ...interface{}) {
+    WithStack(FromContext(ctx)).Panic(args...)
+}
+
+// Context Printf family functions
+func DebugfContext(ctx context.Context, format string, args ...interface{}) {
+    WithStack(FromContext(ctx)).Debugf(format, args...)
+}
+
+func InfofContext(ctx context.Context, format string, args ...interface{}) {
+    WithStack(FromContext(ctx)).Infof(format, args...)
+}
+
+func WarnfContext(ctx context.Context, format string, args ...interface{}) {
+    WithStack(FromContext(ctx)).Warnf(format, args...)
+}
+
+func ErrorfContext(ctx context.Context, format string, args ...interface{}) {
+    WithStack(FromContext(ctx)).Errorf(format, args...)
+}
+
+func FatalfContext(ctx context.Context, format string, args ...interface{}) {
+    WithStack(FromContext(ctx)).Fatalf(format, args...)
+}
+
+func PanicfContext(ctx context.Context, format string, args ...interface{}) {
+    WithStack(FromContext(ctx)).Panicf(format, args...)
+}
@@ -0,0 +1,38 @@
+package log
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+
+    "github.com/sirupsen/logrus"
+)
+
+type traceKey struct{}
+
+func TestContextLogger(t *testing.T) {
+    buf := &bytes.Buffer{}
+    logger := Instance(&Config{Format: "json"})
+    logger.(*defaultLogger).Logger.SetOutput(buf)
+    logger.(*defaultLogger).Logger.SetLevel(logrus.DebugLevel)
+    RegisterContextExtractor(ContextValueExtractor("trace_id", traceKey{}))
+    t.Cleanup(resetContextExtractors)
+
+    ctx := context.WithValue(context.Background(), traceKey{}, "t-123")
+    ctx = NewContext(ctx, logger)
+    ctx = ContextWithFields(ctx, Fields{"user_id": 42})
+    InfofContext(ctx, "hello %s", "world")
+
+    out := buf.String()
+    for _, s := range []string{`"trace_id":"t-123"`, `"user_id":42`, `"msg":"hello world"`, `context_test.go::TestContextLogger:`} {
+        if !strings.Contains(out, s) {
+            t.Fatalf("log should contain %s: %s", s, out)
+        }
+    }
+
+    // 没有logger时使用默认logger
+    if FromContext(context.Background()) != stdLogger {
+        t.Fatal("default logger should be returned")
+    }
+}
@@ -2,6 +2,7 @@ package log
 
 import (
     "fmt"
+    "path"
     "runtime"
     "strings"
     "sync"
@@ -17,6 +18,12 @@ var stdLogger Logger
 // a locker
 var mu sync.Mutex
 
+// logDir 本包源文件所在的目录，用于在调用栈中跳过本包的函数
+var logDir = func() string {
+    _, file, _, _ := runtime.Caller(0)
+    return path.Dir(file)
+}()
+
 // Fields define a map to store log data
 type Fields map[string]interface{}
 
@@ -135,8 +142,8 @@ func WithStack(l Logger) Logger {
             prevCodePath = codePath
             prevCodeLine = codeLine
             prevFuncName = runtime.FuncForPC(pc).Name()
-            if !strings.Contains(prevCodePath, "/goutil/log") {
-                // 找到包外的函数了
+            if path.Dir(prevCodePath) != logDir || strings.HasSuffix(prevCodePath, "_test.go") {
+                // 找到包外的函数了（本包的测试也作为调用方）
                 break
             }
         }
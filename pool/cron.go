@@ -0,0 +1,165 @@
+package pool
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// CronSchedule 解析后的cron表达式
+// 支持5个字段（分 时 日 月 周）或者6个字段（秒 分 时 日 月 周），
+// 每个字段支持 *、数字、范围（a-b）、步长（*/n、a-b/n、a/n）以及逗号分隔的列表，周的取值为0-7，0和7都表示周日，
+// 另外支持 @yearly、@monthly、@weekly、@daily、@hourly 等预定义表达式
+type CronSchedule struct {
+    second, minute, hour, dom, month, dow uint64
+    domStar, dowStar                      bool // 日或周是否为*，两者都不为*时满足任意一个即可
+}
+
+// cronField cron表达式中字段的取值范围
+type cronField struct {
+    name     string
+    min, max int
+}
+
+var (
+    cronSecond = cronField{"second", 0, 59}
+    cronMinute = cronField{"minute", 0, 59}
+    cronHour   = cronField{"hour", 0, 23}
+    cronDom    = cronField{"day of month", 1, 31}
+    cronMonth  = cronField{"month", 1, 12}
+    cronDow    = cronField{"day of week", 0, 7}
+)
+
+// cronDescriptors 预定义的cron表达式
+var cronDescriptors = map[string]string{
+    "@yearly":   "0 0 0 1 1 *",
+    "@annually": "0 0 0 1 1 *",
+    "@monthly":  "0 0 0 1 * *",
+    "@weekly":   "0 0 0 * * 0",
+    "@daily":    "0 0 0 * * *",
+    "@midnight": "0 0 0 * * *",
+    "@hourly":   "0 0 * * * *",
+}
+
+// ParseCron 解析cron表达式
+func ParseCron(spec string) (*CronSchedule, error) {
+    spec = strings.TrimSpace(spec)
+    if v, ok := cronDescriptors[spec]; ok {
+        spec = v
+    }
+    fields := strings.Fields(spec)
+    switch len(fields) {
+    case 5:
+        fields = append([]string{"0"}, fields...)
+    case 6:
+    default:
+        return nil, fmt.Errorf("pool: invalid cron spec %q: expect 5 or 6 fields, got %d", spec, len(fields))
+    }
+    s := &CronSchedule{
+        domStar: fields[3] == "*" || fields[3] == "?",
+        dowStar: fields[5] == "*" || fields[5] == "?",
+    }
+    var err error
+    bits := []*uint64{&s.second, &s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+    for i, f := range []cronField{cronSecond, cronMinute, cronHour, cronDom, cronMonth, cronDow} {
+        if *bits[i], err = parseCronField(fields[i], f); err != nil {
+            return nil, fmt.Errorf("pool: invalid cron spec %q: %v", spec, err)
+        }
+    }
+    // 7和0都表示周日
+    if s.dow&(1<<7) > 0 {
+        s.dow |= 1
+    }
+    return s, nil
+}
+
+// parseCronField 解析cron表达式的一个字段，返回取值的位图
+func parseCronField(expr string, f cronField) (uint64, error) {
+    var bits uint64
+    for _, part := range strings.Split(expr, ",") {
+        rangeExpr, step := part, 1
+        if i := strings.Index(part, "/"); i >= 0 {
+            n, err := strconv.Atoi(part[i+1:])
+            if err != nil || n <= 0 {
+                return 0, fmt.Errorf("invalid step %q in %s field", part, f.name)
+            }
+            rangeExpr, step = part[:i], n
+        }
+        start, end := f.min, f.max
+        switch {
+        case rangeExpr == "*" || rangeExpr == "?":
+        case strings.Contains(rangeExpr, "-"):
+            bounds := strings.SplitN(rangeExpr, "-", 2)
+            var err1, err2 error
+            start, err1 = strconv.Atoi(bounds[0])
+            end, err2 = strconv.Atoi(bounds[1])
+            if err1 != nil || err2 != nil {
+                return 0, fmt.Errorf("invalid range %q in %s field", part, f.name)
+            }
+        default:
+            n, err := strconv.Atoi(rangeExpr)
+            if err != nil {
+                return 0, fmt.Errorf("invalid value %q in %s field", part, f.name)
+            }
+            start = n
+            // 单个值带步长时表示从该值开始到最大值
+            if step == 1 {
+                end = n
+            }
+        }
+        if start < f.min || end > f.max || start > end {
+            return 0, fmt.Errorf("%q out of range [%d, %d] in %s field", part, f.min, f.max, f.name)
+        }
+        for v := start; v <= end; v += step {
+            bits |= 1 << uint(v)
+        }
+    }
+    return bits, nil
+}
+
+// match 判断值是否在位图中
+func match(bits uint64, v int) bool {
+    return bits&(1<<uint(v)) > 0
+}
+
+// dayMatches 判断日期是否满足日和周的限制
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+    domMatch := match(s.dom, t.Day())
+    dowMatch := match(s.dow, int(t.Weekday()))
+    if s.domStar || s.dowStar {
+        return domMatch && dowMatch
+    }
+    return domMatch || dowMatch
+}
+
+// Next 返回t之后（不包括t）第一个满足表达式的时间，使用t的时区，5年内没有满足的时间时返回零值
+func (s *CronSchedule) Next(t time.Time) time.Time {
+    t = t.Truncate(time.Second).Add(time.Second)
+    limit := t.AddDate(5, 0, 0)
+    loc := t.Location()
+    for t.Before(limit) {
+        if !match(s.month, int(t.Month())) {
+            t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+            continue
+        }
+        if !s.dayMatches(t) {
+            t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+            continue
+        }
+        if !match(s.hour, t.Hour()) {
+            t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+            continue
+        }
+        if !match(s.minute, t.Minute()) {
+            t = t.Truncate(time.Minute).Add(time.Minute)
+            continue
+        }
+        if !match(s.second, t.Second()) {
+            t = t.Add(time.Second)
+            continue
+        }
+        return t
+    }
+    return time.Time{}
+}
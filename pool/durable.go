@@ -0,0 +1,462 @@
+package pool
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "reflect"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/whencome/goutil/jsonkit"
+)
+
+var (
+    // ErrUnregisteredJob 任务类型没有注册
+    ErrUnregisteredJob = errors.New("pool: unregistered durable job type")
+)
+
+// ContextJob 可以返回执行结果的任务，在持久化队列中执行失败时会按照退避策略重试
+type ContextJob interface {
+    Job
+    DoContext(ctx context.Context) error
+}
+
+// DurableHandler 持久化任务的处理方法，payload为提交任务时序列化的数据，返回错误或者发生panic时按照退避策略重试
+type DurableHandler func(ctx context.Context, payload []byte) error
+
+// Registry 任务类型名称与处理方法的映射，用于执行从持久化队列中取出的任务
+type Registry struct {
+    mu       sync.RWMutex
+    handlers map[string]DurableHandler
+    names    map[reflect.Type]string
+}
+
+// NewRegistry 创建任务类型注册表
+func NewRegistry() *Registry {
+    return &Registry{
+        handlers: make(map[string]DurableHandler),
+        names:    make(map[reflect.Type]string),
+    }
+}
+
+// Register 注册任务类型的处理方法，重复注册时覆盖之前的处理方法
+func (r *Registry) Register(typeName string, h DurableHandler) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.handlers[typeName] = h
+}
+
+// RegisterJob 注册可序列化的Job类型，newJob用于创建空的任务对象（指针），任务数据通过json反序列化到该对象后执行
+// 任务实现了ContextJob时调用DoContext并根据返回的错误决定是否重试，否则调用Do
+func RegisterJob[T Job](r *Registry, typeName string, newJob func() T) {
+    r.Register(typeName, func(ctx context.Context, payload []byte) error {
+        job := newJob()
+        if err := jsonkit.Unmarshal(payload, job); err != nil {
+            return err
+        }
+        if cj, ok := Job(job).(ContextJob); ok {
+            return cj.DoContext(ctx)
+        }
+        job.Do()
+        return nil
+    })
+    r.mu.Lock()
+    r.names[reflect.TypeOf(newJob())] = typeName
+    r.mu.Unlock()
+}
+
+// handler 返回任务类型的处理方法
+func (r *Registry) handler(typeName string) (DurableHandler, bool) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    h, ok := r.handlers[typeName]
+    return h, ok
+}
+
+// typeName 返回通过RegisterJob注册的任务的类型名称
+func (r *Registry) typeName(job Job) (string, bool) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    name, ok := r.names[reflect.TypeOf(job)]
+    return name, ok
+}
+
+// DurableJob 持久化队列中的任务数据
+type DurableJob struct {
+    ID          string `json:"id"`
+    Type        string `json:"type"`
+    Payload     []byte `json:"payload"`
+    MaxAttempts int    `json:"max_attempts"`         // 最大执行次数
+    Attempts    int    `json:"attempts"`             // 已经执行的次数
+    LastError   string `json:"last_error,omitempty"` // 最近一次执行失败的原因
+    CreatedAt   int64  `json:"created_at"`           // 创建时间，毫秒时间戳
+    FailedAt    int64  `json:"failed_at,omitempty"`  // 最近一次执行失败的时间，毫秒时间戳
+}
+
+// durableOptions 持久化队列的可选参数
+type durableOptions struct {
+    maxAttempts  int           // 默认的最大执行次数
+    visibility   time.Duration // 取出后多久没有确认则重新投递
+    backoffBase  time.Duration // 第一次重试的等待时间
+    backoffMax   time.Duration // 重试等待时间的上限
+    pollInterval time.Duration // 队列为空时的轮询间隔
+    prefetch     int           // 同时取出等待执行的最大任务数
+}
+
+// DurableOption 设置持久化队列的可选参数
+type DurableOption func(o *durableOptions)
+
+// WithMaxAttempts 设置任务默认的最大执行次数，超过后写入死信列表，默认为5次
+func WithMaxAttempts(n int) DurableOption {
+    return func(o *durableOptions) {
+        if n > 0 {
+            o.maxAttempts = n
+        }
+    }
+}
+
+// WithVisibilityTimeout 设置任务取出后多久没有执行完成则重新投递，默认为30秒，应大于任务的最长执行时间
+// 任务的ctx在超时时被取消
+func WithVisibilityTimeout(d time.Duration) DurableOption {
+    return func(o *durableOptions) {
+        if d > 0 {
+            o.visibility = d
+        }
+    }
+}
+
+// WithBackoff 设置重试的等待时间，第n次重试等待base*2^(n-1)，最长不超过max，默认为1秒和10分钟
+func WithBackoff(base, max time.Duration) DurableOption {
+    return func(o *durableOptions) {
+        if base > 0 {
+            o.backoffBase = base
+        }
+        if max > 0 {
+            o.backoffMax = max
+        }
+    }
+}
+
+// WithPollInterval 设置队列为空时的轮询间隔，默认为1秒
+func WithPollInterval(d time.Duration) DurableOption {
+    return func(o *durableOptions) {
+        if d > 0 {
+            o.pollInterval = d
+        }
+    }
+}
+
+// WithPrefetch 设置同时取出等待执行的最大任务数，默认为10
+func WithPrefetch(n int) DurableOption {
+    return func(o *durableOptions) {
+        if n > 0 {
+            o.prefetch = n
+        }
+    }
+}
+
+// EnqueueOption 设置写入任务时的可选参数
+type EnqueueOption func(job *DurableJob, runAt *time.Time)
+
+// WithEnqueueDelay 设置任务在delay之后执行
+func WithEnqueueDelay(delay time.Duration) EnqueueOption {
+    return func(job *DurableJob, runAt *time.Time) {
+        *runAt = runAt.Add(delay)
+    }
+}
+
+// WithEnqueueMaxAttempts 设置任务的最大执行次数，覆盖队列的默认值
+func WithEnqueueMaxAttempts(n int) EnqueueOption {
+    return func(job *DurableJob, runAt *time.Time) {
+        if n > 0 {
+            job.MaxAttempts = n
+        }
+    }
+}
+
+// DurableQueue 持久化任务队列，任务写入存储（如redis）后由任务池执行，进程重启后未完成的任务不会丢失
+// 任务至少执行一次：执行中的任务在可见性超时后会被重新投递，因此处理方法需要保证幂等
+type DurableQueue struct {
+    name     string
+    store    DurableStore
+    registry *Registry
+    wp       *WorkerPool
+    opts     *durableOptions
+    mu       sync.Mutex
+    started  bool
+    quit     chan struct{}
+    sem      chan struct{} // 限制取出的任务数
+    wg       sync.WaitGroup
+    lost     int64 // 确认时已经超时被重新投递的任务数
+}
+
+// DurableStats 持久化队列的状态
+type DurableStats struct {
+    Ready    int64 // 等待执行的任务数
+    Delayed  int64 // 延迟执行（包括等待重试）的任务数
+    Inflight int64 // 执行中的任务数
+    Dead     int64 // 死信列表中的任务数
+    Lost     int64 // 当前进程执行完成后确认时，任务已经超时被重新投递的次数，持续增长说明可见性超时小于任务的执行时间
+}
+
+// NewDurableQueue 创建持久化任务队列，取出的任务提交到wp执行，registry用于根据任务类型查找处理方法
+func NewDurableQueue(wp *WorkerPool, store DurableStore, name string, registry *Registry, opts ...DurableOption) *DurableQueue {
+    o := &durableOptions{
+        maxAttempts:  5,
+        visibility:   time.Second * 30,
+        backoffBase:  time.Second,
+        backoffMax:   time.Minute * 10,
+        pollInterval: time.Second,
+        prefetch:     10,
+    }
+    for _, opt := range opts {
+        if opt != nil {
+            opt(o)
+        }
+    }
+    return &DurableQueue{
+        name:     name,
+        store:    store,
+        registry: registry,
+        wp:       wp,
+        opts:     o,
+        quit:     make(chan struct{}),
+        sem:      make(chan struct{}, o.prefetch),
+    }
+}
+
+// newDurableID 生成任务id
+func newDurableID() string {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return fmt.Sprintf("%x", time.Now().UnixNano())
+    }
+    return hex.EncodeToString(buf)
+}
+
+// nowMillis 返回毫秒时间戳
+func nowMillis(t time.Time) int64 {
+    return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Enqueue 写入任务，payload使用json序列化（[]byte原样写入），返回任务id
+func (q *DurableQueue) Enqueue(typeName string, payload interface{}, opts ...EnqueueOption) (string, error) {
+    data, ok := payload.([]byte)
+    if !ok {
+        var err error
+        if data, err = jsonkit.Marshal(payload); err != nil {
+            return "", err
+        }
+    }
+    now := time.Now()
+    job := &DurableJob{
+        ID:          newDurableID(),
+        Type:        typeName,
+        Payload:     data,
+        MaxAttempts: q.opts.maxAttempts,
+        CreatedAt:   nowMillis(now),
+    }
+    runAt := now
+    for _, opt := range opts {
+        if opt != nil {
+            opt(job, &runAt)
+        }
+    }
+    raw, err := jsonkit.Marshal(job)
+    if err != nil {
+        return "", err
+    }
+    if err = q.store.Push(q.name, job.ID, raw, nowMillis(runAt), nowMillis(now)); err != nil {
+        return "", err
+    }
+    return job.ID, nil
+}
+
+// EnqueueJob 写入通过RegisterJob注册的任务，任务使用json序列化，返回任务id
+func (q *DurableQueue) EnqueueJob(job Job, opts ...EnqueueOption) (string, error) {
+    typeName, ok := q.registry.typeName(job)
+    if !ok {
+        return "", ErrUnregisteredJob
+    }
+    return q.Enqueue(typeName, job, opts...)
+}
+
+// Start 启动后台协程，从存储中取出任务并提交到任务池执行
+func (q *DurableQueue) Start() {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    if q.started {
+        return
+    }
+    q.started = true
+    q.wg.Add(1)
+    go q.fetch()
+}
+
+// Stop 停止取出任务，并等待已经取出的任务执行完成，未确认的任务在可见性超时后由其他消费者重新执行
+func (q *DurableQueue) Stop() {
+    q.mu.Lock()
+    select {
+    case <-q.quit:
+    default:
+        close(q.quit)
+    }
+    q.mu.Unlock()
+    q.wg.Wait()
+}
+
+// fetch 循环取出任务，没有任务或者出错时等待轮询间隔
+func (q *DurableQueue) fetch() {
+    defer q.wg.Done()
+    for {
+        select {
+        case q.sem <- struct{}{}:
+        case <-q.quit:
+            return
+        }
+        now := time.Now()
+        msg, err := q.store.Pop(q.name, nowMillis(now), nowMillis(now.Add(q.opts.visibility)))
+        if err != nil || msg == nil {
+            <-q.sem
+            select {
+            case <-time.After(q.opts.pollInterval):
+                continue
+            case <-q.quit:
+                return
+            }
+        }
+        q.wg.Add(1)
+        // 未被任务池接收的任务在可见性超时后重新投递
+        if err = q.wp.Submit(&durableRun{q: q, msg: msg}); err == ErrPoolClosed {
+            return
+        } else if err != nil {
+            // 队列已满等情况，等待轮询间隔后继续
+            select {
+            case <-time.After(q.opts.pollInterval):
+            case <-q.quit:
+                return
+            }
+        }
+    }
+}
+
+// backoff 返回第attempts次执行失败后的重试等待时间
+func (q *DurableQueue) backoff(attempts int) time.Duration {
+    d := q.opts.backoffBase
+    for i := 1; i < attempts && d < q.opts.backoffMax; i++ {
+        d *= 2
+    }
+    if d > q.opts.backoffMax {
+        d = q.opts.backoffMax
+    }
+    return d
+}
+
+// process 执行任务并根据结果确认、重试或者写入死信列表
+func (q *DurableQueue) process(msg *DurableMessage) error {
+    job := &DurableJob{}
+    if err := jsonkit.Unmarshal(msg.Data, job); err != nil {
+        // 无法解析的数据直接写入死信列表
+        return q.settled(q.store.Bury(q.name, msg.ID, msg.Attempts, msg.Data))
+    }
+    job.Attempts = msg.Attempts
+
+    var err error
+    if h, ok := q.registry.handler(job.Type); ok {
+        err = q.handle(h, job.Payload, time.UnixMilli(msg.VisibleUntil))
+    } else {
+        err = ErrUnregisteredJob
+    }
+    if err == nil {
+        return q.settled(q.store.Ack(q.name, msg.ID, msg.Attempts))
+    }
+
+    now := time.Now()
+    job.LastError = err.Error()
+    job.FailedAt = nowMillis(now)
+    raw, mErr := jsonkit.Marshal(job)
+    if mErr != nil {
+        return mErr
+    }
+    if err == ErrUnregisteredJob || job.Attempts >= job.MaxAttempts {
+        return q.settled(q.store.Bury(q.name, msg.ID, msg.Attempts, raw))
+    }
+    return q.settled(q.store.Retry(q.name, msg.ID, msg.Attempts, raw, nowMillis(now.Add(q.backoff(job.Attempts)))))
+}
+
+// settled 处理确认的结果，确认时任务已经超时被重新投递（ok为false）则计入Lost
+func (q *DurableQueue) settled(ok bool, err error) error {
+    if err == nil && !ok {
+        atomic.AddInt64(&q.lost, 1)
+    }
+    return err
+}
+
+// handle 执行处理方法，到达本次投递的可见性超时时间（deadline）后取消ctx，避免与重新投递的任务同时执行
+// panic作为*PanicError返回
+func (q *DurableQueue) handle(h DurableHandler, payload []byte, deadline time.Time) (err error) {
+    ctx, cancel := context.WithDeadline(q.wp.Context(), deadline)
+    defer cancel()
+    defer func() {
+        if v := recover(); v != nil {
+            err = newPanicError(v)
+        }
+    }()
+    return h(ctx, payload)
+}
+
+// DeadLetters 返回死信列表中最新的n个任务
+func (q *DurableQueue) DeadLetters(n int) ([]*DurableJob, error) {
+    items, err := q.store.DeadLetters(q.name, n)
+    if err != nil {
+        return nil, err
+    }
+    jobs := make([]*DurableJob, 0, len(items))
+    for _, data := range items {
+        job := &DurableJob{}
+        if err = jsonkit.Unmarshal(data, job); err != nil {
+            return nil, err
+        }
+        jobs = append(jobs, job)
+    }
+    return jobs, nil
+}
+
+// Stats 返回持久化队列的状态
+func (q *DurableQueue) Stats() (DurableStats, error) {
+    ready, delayed, inflight, dead, err := q.store.Stats(q.name)
+    return DurableStats{
+        Ready:    ready,
+        Delayed:  delayed,
+        Inflight: inflight,
+        Dead:     dead,
+        Lost:     atomic.LoadInt64(&q.lost),
+    }, err
+}
+
+// durableRun 提交到任务池的持久化任务
+type durableRun struct {
+    q   *DurableQueue
+    msg *DurableMessage
+}
+
+// Do 执行任务，存储出错时任务在可见性超时后重新投递
+func (r *durableRun) Do() {
+    defer r.release()
+    _ = r.q.process(r.msg)
+}
+
+// reject 任务未被任务池执行，在可见性超时后重新投递
+func (r *durableRun) reject(err error) {
+    r.release()
+}
+
+// release 释放取出任务的名额
+func (r *durableRun) release() {
+    <-r.q.sem
+    r.q.wg.Done()
+}
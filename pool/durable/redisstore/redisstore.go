@@ -0,0 +1,168 @@
+// Package redisstore 基于redis的持久化队列存储，用于pool.NewDurableQueue
+// 消息的投递、确认以及重新投递均通过lua脚本原子执行，多个进程可以消费同一个队列
+package redisstore
+
+import (
+    "github.com/gomodule/redigo/redis"
+    "github.com/whencome/goutil/cachex"
+    "github.com/whencome/goutil/pool"
+)
+
+// keyPrefix 持久化队列在redis中的key前缀
+const keyPrefix = "pool:durable:"
+
+// queueKeys 队列在redis中使用的key，使用hash tag保证在集群中位于同一个slot
+func queueKeys(queue string) []interface{} {
+    prefix := keyPrefix + "{" + queue + "}:"
+    return []interface{}{
+        prefix + "ready",    // 等待执行的消息id列表
+        prefix + "delayed",  // 延迟执行的消息id，score为可以执行的时间
+        prefix + "inflight", // 执行中的消息id，score为超时时间
+        prefix + "jobs",     // 消息数据
+        prefix + "attempts", // 消息的投递次数
+        prefix + "dead",     // 死信列表
+    }
+}
+
+var (
+    // pushScript 写入消息
+    pushScript = redis.NewScript(6, `
+redis.call('HSET', KEYS[4], ARGV[1], ARGV[2])
+if tonumber(ARGV[3]) <= tonumber(ARGV[4]) then
+    redis.call('LPUSH', KEYS[1], ARGV[1])
+else
+    redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+end
+return 1
+`)
+    // popScript 将到期的延迟消息以及超时的执行中消息移入等待列表，然后取出一条消息
+    popScript = redis.NewScript(6, `
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, id in ipairs(due) do
+    redis.call('ZREM', KEYS[2], id)
+    redis.call('LPUSH', KEYS[1], id)
+end
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, id in ipairs(expired) do
+    redis.call('ZREM', KEYS[3], id)
+    redis.call('RPUSH', KEYS[1], id)
+end
+while true do
+    local id = redis.call('RPOP', KEYS[1])
+    if not id then
+        return false
+    end
+    local data = redis.call('HGET', KEYS[4], id)
+    if data then
+        redis.call('ZADD', KEYS[3], ARGV[2], id)
+        local n = redis.call('HINCRBY', KEYS[5], id, 1)
+        return {id, data, n}
+    end
+end
+`)
+    // ackScript 删除执行中的消息，投递次数不一致说明消息已经被重新投递
+    ackScript = redis.NewScript(6, `
+if redis.call('HGET', KEYS[5], ARGV[1]) ~= ARGV[2] or redis.call('ZREM', KEYS[3], ARGV[1]) == 0 then
+    return 0
+end
+redis.call('HDEL', KEYS[4], ARGV[1])
+redis.call('HDEL', KEYS[5], ARGV[1])
+return 1
+`)
+    // retryScript 将执行中的消息移入延迟列表
+    retryScript = redis.NewScript(6, `
+if redis.call('HGET', KEYS[5], ARGV[1]) ~= ARGV[2] or redis.call('ZREM', KEYS[3], ARGV[1]) == 0 then
+    return 0
+end
+redis.call('HSET', KEYS[4], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+return 1
+`)
+    // buryScript 删除执行中的消息并写入死信列表
+    buryScript = redis.NewScript(6, `
+if redis.call('HGET', KEYS[5], ARGV[1]) ~= ARGV[2] or redis.call('ZREM', KEYS[3], ARGV[1]) == 0 then
+    return 0
+end
+redis.call('HDEL', KEYS[4], ARGV[1])
+redis.call('HDEL', KEYS[5], ARGV[1])
+redis.call('LPUSH', KEYS[6], ARGV[3])
+return 1
+`)
+)
+
+// store 基于redis的持久化队列存储
+type store struct {
+    provider cachex.Provider
+}
+
+// New 使用cachex的Provider创建基于redis的持久化队列存储，每次操作都会获取新的连接并在使用后关闭
+func New(provider cachex.Provider) pool.DurableStore {
+    return &store{provider: provider}
+}
+
+// eval 执行脚本
+func (s *store) eval(script *redis.Script, queue string, args ...interface{}) (interface{}, error) {
+    conn := s.provider.Redis()
+    defer conn.Close()
+    return script.Do(conn, append(queueKeys(queue), args...)...)
+}
+
+func (s *store) Push(queue, id string, data []byte, runAt, now int64) error {
+    _, err := s.eval(pushScript, queue, id, data, runAt, now)
+    return err
+}
+
+func (s *store) Pop(queue string, now, visibleUntil int64) (*pool.DurableMessage, error) {
+    values, err := redis.Values(s.eval(popScript, queue, now, visibleUntil))
+    if err == redis.ErrNil {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    msg := &pool.DurableMessage{VisibleUntil: visibleUntil}
+    if _, err = redis.Scan(values, &msg.ID, &msg.Data, &msg.Attempts); err != nil {
+        return nil, err
+    }
+    return msg, nil
+}
+
+func (s *store) Ack(queue, id string, attempts int) (bool, error) {
+    return redis.Bool(s.eval(ackScript, queue, id, attempts))
+}
+
+func (s *store) Retry(queue, id string, attempts int, data []byte, runAt int64) (bool, error) {
+    return redis.Bool(s.eval(retryScript, queue, id, attempts, data, runAt))
+}
+
+func (s *store) Bury(queue, id string, attempts int, data []byte) (bool, error) {
+    return redis.Bool(s.eval(buryScript, queue, id, attempts, data))
+}
+
+func (s *store) DeadLetters(queue string, n int) ([][]byte, error) {
+    if n <= 0 {
+        return nil, nil
+    }
+    conn := s.provider.Redis()
+    defer conn.Close()
+    return redis.ByteSlices(conn.Do("LRANGE", queueKeys(queue)[5], 0, n-1))
+}
+
+func (s *store) Stats(queue string) (ready, delayed, inflight, dead int64, err error) {
+    keys := queueKeys(queue)
+    conn := s.provider.Redis()
+    defer conn.Close()
+    _ = conn.Send("LLEN", keys[0])
+    _ = conn.Send("ZCARD", keys[1])
+    _ = conn.Send("ZCARD", keys[2])
+    _ = conn.Send("LLEN", keys[5])
+    if err = conn.Flush(); err != nil {
+        return
+    }
+    for _, v := range []*int64{&ready, &delayed, &inflight, &dead} {
+        if *v, err = redis.Int64(conn.Receive()); err != nil {
+            return
+        }
+    }
+    return
+}
@@ -0,0 +1,154 @@
+package redisstore
+
+import (
+    "context"
+    "errors"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+    "github.com/gomodule/redigo/redis"
+    "github.com/whencome/goutil/pool"
+)
+
+// miniredisProvider 连接miniredis的cachex.Provider
+type miniredisProvider struct {
+    addr string
+}
+
+func (p miniredisProvider) Redis() redis.Conn {
+    conn, err := redis.Dial("tcp", p.addr)
+    if err != nil {
+        panic(err)
+    }
+    return conn
+}
+
+// testStore 测试DurableStore的投递、重新投递以及确认，与pool中内存实现的测试保持一致
+func testStore(t *testing.T, s pool.DurableStore) {
+    if err := s.Push("q", "1", []byte("a"), 0, 0); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Push("q", "2", []byte("b"), 100, 0); err != nil {
+        t.Fatal(err)
+    }
+    first, err := s.Pop("q", 10, 50)
+    if err != nil || first == nil || first.ID != "1" || first.Attempts != 1 || first.VisibleUntil != 50 {
+        t.Fatalf("unexpected message: %+v, %v", first, err)
+    }
+    // 延迟消息未到期
+    if msg, _ := s.Pop("q", 20, 70); msg != nil {
+        t.Fatalf("unexpected message: %+v", msg)
+    }
+    // 超时未确认的消息优先重新投递
+    second, _ := s.Pop("q", 120, 200)
+    if second == nil || second.ID != "1" || second.Attempts != 2 || string(second.Data) != "a" {
+        t.Fatalf("unexpected message: %+v", second)
+    }
+    // 重新投递后之前的投递不能再确认、重试或者写入死信列表
+    if ok, err := s.Ack("q", first.ID, first.Attempts); err != nil || ok {
+        t.Fatalf("stale delivery should not be acked: %v, %v", ok, err)
+    }
+    if ok, _ := s.Retry("q", first.ID, first.Attempts, []byte("x"), 0); ok {
+        t.Fatal("stale delivery should not be retried")
+    }
+    if ok, _ := s.Bury("q", first.ID, first.Attempts, []byte("x")); ok {
+        t.Fatal("stale delivery should not be buried")
+    }
+    if ok, _ := s.Ack("q", "2", 1); ok {
+        t.Fatal("message not in flight should not be acked")
+    }
+    if ok, err := s.Retry("q", second.ID, second.Attempts, []byte("c"), 150); err != nil || !ok {
+        t.Fatalf("message should be retried: %v, %v", ok, err)
+    }
+    third, _ := s.Pop("q", 150, 250)
+    if third == nil || third.ID != "2" {
+        t.Fatalf("unexpected message: %+v", third)
+    }
+    if ok, err := s.Ack("q", third.ID, third.Attempts); err != nil || !ok {
+        t.Fatalf("message should be acked: %v, %v", ok, err)
+    }
+    // 重试的消息使用更新后的数据
+    fourth, _ := s.Pop("q", 150, 250)
+    if fourth == nil || fourth.ID != "1" || fourth.Attempts != 3 || string(fourth.Data) != "c" {
+        t.Fatalf("unexpected message: %+v", fourth)
+    }
+    ready, delayed, inflight, dead, err := s.Stats("q")
+    if err != nil || ready != 0 || delayed != 0 || inflight != 1 || dead != 0 {
+        t.Fatalf("unexpected stats: %d %d %d %d, %v", ready, delayed, inflight, dead, err)
+    }
+    if ok, err := s.Bury("q", fourth.ID, fourth.Attempts, []byte("dead")); err != nil || !ok {
+        t.Fatalf("message should be buried: %v, %v", ok, err)
+    }
+    letters, err := s.DeadLetters("q", 10)
+    if err != nil || len(letters) != 1 || string(letters[0]) != "dead" {
+        t.Fatalf("unexpected dead letters: %q, %v", letters, err)
+    }
+    if msg, _ := s.Pop("q", 1000, 2000); msg != nil {
+        t.Fatalf("unexpected message: %+v", msg)
+    }
+}
+
+func TestStore(t *testing.T) {
+    mr := miniredis.RunT(t)
+    testStore(t, New(miniredisProvider{addr: mr.Addr()}))
+}
+
+// waitStats 等待持久化队列的状态满足条件
+func waitStats(t *testing.T, q *pool.DurableQueue, cond func(s pool.DurableStats) bool) {
+    deadline := time.Now().Add(time.Second * 3)
+    for {
+        s, err := q.Stats()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if cond(s) {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("unexpected durable stats: %+v", s)
+        }
+        time.Sleep(time.Millisecond * 10)
+    }
+}
+
+func TestDurableQueue(t *testing.T) {
+    mr := miniredis.RunT(t)
+    p := pool.NewWorkerPool(2, 10)
+    p.Run()
+    defer p.Stop()
+    registry := pool.NewRegistry()
+    var attempts, sent int32
+    registry.Register("email", func(ctx context.Context, payload []byte) error {
+        atomic.AddInt32(&attempts, 1)
+        // 第一个任务失败1次后成功，第二个任务总是失败
+        if string(payload) == `"b"` || atomic.LoadInt32(&attempts) == 1 {
+            return errors.New("smtp unavailable")
+        }
+        atomic.AddInt32(&sent, 1)
+        return nil
+    })
+    q := pool.NewDurableQueue(p, New(miniredisProvider{addr: mr.Addr()}), "redis", registry,
+        pool.WithBackoff(time.Millisecond*10, time.Millisecond*40),
+        pool.WithPollInterval(time.Millisecond*10),
+        pool.WithMaxAttempts(2))
+    q.Start()
+    defer q.Stop()
+
+    if _, err := q.Enqueue("email", "a"); err != nil {
+        t.Fatal(err)
+    }
+    waitStats(t, q, func(s pool.DurableStats) bool {
+        return atomic.LoadInt32(&sent) == 1 && s.Inflight == 0 && s.Delayed == 0
+    })
+    if _, err := q.Enqueue("email", "b"); err != nil {
+        t.Fatal(err)
+    }
+    // 超过最大执行次数后写入死信列表
+    waitStats(t, q, func(s pool.DurableStats) bool { return s.Dead == 1 && s.Inflight == 0 })
+    jobs, err := q.DeadLetters(1)
+    if err != nil || len(jobs) != 1 || jobs[0].Attempts != 2 || jobs[0].LastError == "" {
+        t.Fatalf("unexpected dead letters: %+v, %v", jobs, err)
+    }
+}
@@ -0,0 +1,195 @@
+package pool
+
+import (
+    "sort"
+    "sync"
+)
+
+// DurableMessage 从持久化队列中取出的消息
+type DurableMessage struct {
+    ID           string
+    Data         []byte
+    Attempts     int   // 包括本次在内的投递次数，同时用于识别本次投递，确认消息时需要传入
+    VisibleUntil int64 // 本次投递的可见性超时时间，毫秒时间戳，之后消息会被重新投递
+}
+
+// DurableStore 持久化队列的存储，时间参数均为毫秒时间戳，基于redis的实现见pool/durable/redisstore
+// 取出的消息在visibleUntil之前没有被确认（Ack、Retry或者Bury）时会被重新投递
+// 确认消息时需要传入Pop返回的Attempts，只有最近一次投递可以确认，之前的投递（已经超时被重新投递）确认时返回false
+type DurableStore interface {
+    // Push 写入消息，runAt不晚于now时立即可以取出，否则在runAt之后可以取出
+    Push(queue, id string, data []byte, runAt, now int64) error
+    // Pop 取出一条可以执行的消息，没有消息时返回nil
+    Pop(queue string, now, visibleUntil int64) (*DurableMessage, error)
+    // Ack 确认消息执行成功并删除消息，消息已经超时被重新投递时返回false
+    Ack(queue, id string, attempts int) (bool, error)
+    // Retry 更新消息数据并在runAt之后重新投递，消息已经超时被重新投递时返回false
+    Retry(queue, id string, attempts int, data []byte, runAt int64) (bool, error)
+    // Bury 删除消息并将data写入死信列表，消息已经超时被重新投递时返回false
+    Bury(queue, id string, attempts int, data []byte) (bool, error)
+    // DeadLetters 返回死信列表中最新的n条数据
+    DeadLetters(queue string, n int) ([][]byte, error)
+    // Stats 返回等待执行、延迟执行、执行中以及死信的消息数量
+    Stats(queue string) (ready, delayed, inflight, dead int64, err error)
+}
+
+// memoryDurableQueue 内存中的一个队列
+type memoryDurableQueue struct {
+    ready    []string         // 等待执行的消息id，从头部取出
+    delayed  map[string]int64 // 延迟执行的消息id以及可以执行的时间
+    inflight map[string]int64 // 执行中的消息id以及超时时间
+    jobs     map[string][]byte
+    attempts map[string]int
+    dead     [][]byte // 最新的数据在前
+}
+
+// memoryDurableStore 基于内存的持久化队列存储，数据不会持久化，用于测试以及单机场景
+type memoryDurableStore struct {
+    mu     sync.Mutex
+    queues map[string]*memoryDurableQueue
+}
+
+// NewMemoryDurableStore 创建基于内存的持久化队列存储，进程退出后数据丢失，主要用于测试
+func NewMemoryDurableStore() DurableStore {
+    return &memoryDurableStore{queues: make(map[string]*memoryDurableQueue)}
+}
+
+// queue 返回队列，不存在时创建，调用方需持有锁
+func (s *memoryDurableStore) queue(name string) *memoryDurableQueue {
+    q, ok := s.queues[name]
+    if !ok {
+        q = &memoryDurableQueue{
+            delayed:  make(map[string]int64),
+            inflight: make(map[string]int64),
+            jobs:     make(map[string][]byte),
+            attempts: make(map[string]int),
+        }
+        s.queues[name] = q
+    }
+    return q
+}
+
+// dueIDs 返回到期的消息id，按到期时间排序
+func dueIDs(items map[string]int64, now int64) []string {
+    var ids []string
+    for id, at := range items {
+        if at <= now {
+            ids = append(ids, id)
+        }
+    }
+    sort.Slice(ids, func(i, j int) bool {
+        return items[ids[i]] < items[ids[j]]
+    })
+    return ids
+}
+
+func (s *memoryDurableStore) Push(queue, id string, data []byte, runAt, now int64) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    q := s.queue(queue)
+    q.jobs[id] = append([]byte(nil), data...)
+    if runAt <= now {
+        q.ready = append(q.ready, id)
+    } else {
+        q.delayed[id] = runAt
+    }
+    return nil
+}
+
+func (s *memoryDurableStore) Pop(queue string, now, visibleUntil int64) (*DurableMessage, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    q := s.queue(queue)
+    for _, id := range dueIDs(q.delayed, now) {
+        delete(q.delayed, id)
+        q.ready = append(q.ready, id)
+    }
+    // 超时的消息优先重新投递
+    for _, id := range dueIDs(q.inflight, now) {
+        delete(q.inflight, id)
+        q.ready = append([]string{id}, q.ready...)
+    }
+    for len(q.ready) > 0 {
+        id := q.ready[0]
+        q.ready = q.ready[1:]
+        data, ok := q.jobs[id]
+        if !ok {
+            continue
+        }
+        q.inflight[id] = visibleUntil
+        q.attempts[id]++
+        return &DurableMessage{
+            ID:           id,
+            Data:         append([]byte(nil), data...),
+            Attempts:     q.attempts[id],
+            VisibleUntil: visibleUntil,
+        }, nil
+    }
+    return nil, nil
+}
+
+// settle 删除执行中的消息，消息不在执行中或者已经被重新投递时返回false，调用方需持有锁
+func (q *memoryDurableQueue) settle(id string, attempts int) bool {
+    if _, ok := q.inflight[id]; !ok || q.attempts[id] != attempts {
+        return false
+    }
+    delete(q.inflight, id)
+    return true
+}
+
+func (s *memoryDurableStore) Ack(queue, id string, attempts int) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    q := s.queue(queue)
+    if !q.settle(id, attempts) {
+        return false, nil
+    }
+    delete(q.jobs, id)
+    delete(q.attempts, id)
+    return true, nil
+}
+
+func (s *memoryDurableStore) Retry(queue, id string, attempts int, data []byte, runAt int64) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    q := s.queue(queue)
+    if !q.settle(id, attempts) {
+        return false, nil
+    }
+    q.jobs[id] = append([]byte(nil), data...)
+    q.delayed[id] = runAt
+    return true, nil
+}
+
+func (s *memoryDurableStore) Bury(queue, id string, attempts int, data []byte) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    q := s.queue(queue)
+    if !q.settle(id, attempts) {
+        return false, nil
+    }
+    delete(q.jobs, id)
+    delete(q.attempts, id)
+    q.dead = append([][]byte{append([]byte(nil), data...)}, q.dead...)
+    return true, nil
+}
+
+func (s *memoryDurableStore) DeadLetters(queue string, n int) ([][]byte, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    q := s.queue(queue)
+    if n > len(q.dead) {
+        n = len(q.dead)
+    }
+    if n <= 0 {
+        return nil, nil
+    }
+    return append([][]byte(nil), q.dead[:n]...), nil
+}
+
+func (s *memoryDurableStore) Stats(queue string) (ready, delayed, inflight, dead int64, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    q := s.queue(queue)
+    return int64(len(q.ready)), int64(len(q.delayed)), int64(len(q.inflight)), int64(len(q.dead)), nil
+}
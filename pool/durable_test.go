@@ -0,0 +1,296 @@
+package pool
+
+import (
+    "context"
+    "errors"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// 定义一个可以持久化的任务对象，前Fails次执行失败
+type EmailJob struct {
+    To    string `json:"to"`
+    Fails int32  `json:"fails"`
+}
+
+var emailAttempts, emailSent int32
+
+func (j *EmailJob) Do() {}
+
+func (j *EmailJob) DoContext(ctx context.Context) error {
+    if atomic.AddInt32(&emailAttempts, 1) <= j.Fails {
+        return errors.New("smtp unavailable")
+    }
+    atomic.AddInt32(&emailSent, 1)
+    return nil
+}
+
+// waitDurable 等待持久化队列的状态满足条件
+func waitDurable(t *testing.T, q *DurableQueue, cond func(s DurableStats) bool) DurableStats {
+    deadline := time.Now().Add(time.Second * 3)
+    for {
+        s, err := q.Stats()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if cond(s) {
+            return s
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("unexpected durable stats: %+v", s)
+        }
+        time.Sleep(time.Millisecond * 10)
+    }
+}
+
+func TestDurableQueue(t *testing.T) {
+    atomic.StoreInt32(&emailAttempts, 0)
+    atomic.StoreInt32(&emailSent, 0)
+    p := NewWorkerPool(2, 10)
+    p.Run()
+    defer p.Stop()
+    registry := NewRegistry()
+    RegisterJob(registry, "email", func() *EmailJob { return &EmailJob{} })
+    var raw int32
+    registry.Register("raw", func(ctx context.Context, payload []byte) error {
+        if string(payload) != "hello" {
+            return errors.New("unexpected payload")
+        }
+        atomic.AddInt32(&raw, 1)
+        return nil
+    })
+    q := NewDurableQueue(p, NewMemoryDurableStore(), "test", registry,
+        WithBackoff(time.Millisecond*10, time.Millisecond*40),
+        WithPollInterval(time.Millisecond*10),
+        WithMaxAttempts(3))
+    q.Start()
+    defer q.Stop()
+
+    // 失败2次后成功
+    if _, err := q.EnqueueJob(&EmailJob{To: "a@example.com", Fails: 2}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := q.Enqueue("raw", []byte("hello"), WithEnqueueDelay(time.Millisecond*50)); err != nil {
+        t.Fatal(err)
+    }
+    waitDurable(t, q, func(s DurableStats) bool {
+        return atomic.LoadInt32(&emailSent) == 1 && atomic.LoadInt32(&raw) == 1 && s.Inflight == 0
+    })
+    if n := atomic.LoadInt32(&emailAttempts); n != 3 {
+        t.Fatalf("expect 3 attempts, got %d", n)
+    }
+
+    // 未注册的类型
+    if _, err := q.EnqueueJob(&CountJob{}); err != ErrUnregisteredJob {
+        t.Fatalf("expect ErrUnregisteredJob, got %v", err)
+    }
+}
+
+func TestDurableQueueDeadLetter(t *testing.T) {
+    p := NewWorkerPool(1, 10)
+    p.Run()
+    defer p.Stop()
+    registry := NewRegistry()
+    var attempts int32
+    registry.Register("fail", func(ctx context.Context, payload []byte) error {
+        atomic.AddInt32(&attempts, 1)
+        panic("always fail")
+    })
+    q := NewDurableQueue(p, NewMemoryDurableStore(), "dead", registry,
+        WithBackoff(time.Millisecond, time.Millisecond*5),
+        WithPollInterval(time.Millisecond*5))
+    q.Start()
+    defer q.Stop()
+
+    id, err := q.Enqueue("fail", map[string]int{"n": 1}, WithEnqueueMaxAttempts(2))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err = q.Enqueue("unknown", nil); err != nil {
+        t.Fatal(err)
+    }
+    waitDurable(t, q, func(s DurableStats) bool { return s.Dead == 2 })
+    if n := atomic.LoadInt32(&attempts); n != 2 {
+        t.Fatalf("expect 2 attempts, got %d", n)
+    }
+    jobs, err := q.DeadLetters(10)
+    if err != nil || len(jobs) != 2 {
+        t.Fatalf("unexpected dead letters: %v, %v", jobs, err)
+    }
+    for _, job := range jobs {
+        switch job.Type {
+        case "fail":
+            if job.ID != id || job.Attempts != 2 || job.LastError == "" || string(job.Payload) != `{"n":1}` {
+                t.Fatalf("unexpected dead job: %+v", job)
+            }
+        case "unknown":
+            if job.LastError != ErrUnregisteredJob.Error() || job.Attempts != 1 {
+                t.Fatalf("unexpected dead job: %+v", job)
+            }
+        default:
+            t.Fatalf("unexpected dead job: %+v", job)
+        }
+    }
+}
+
+func TestDurableQueueLeaseDeadline(t *testing.T) {
+    p := NewWorkerPool(1, 10)
+    p.Run()
+    defer p.Stop()
+    registry := NewRegistry()
+    started := make(chan time.Time, 1)
+    deadlines := make(chan time.Time, 1)
+    registry.Register("lease", func(ctx context.Context, payload []byte) error {
+        deadline, _ := ctx.Deadline()
+        started <- time.Now()
+        deadlines <- deadline
+        return nil
+    })
+    q := NewDurableQueue(p, NewMemoryDurableStore(), "lease", registry,
+        WithVisibilityTimeout(time.Second),
+        WithPollInterval(time.Millisecond*5))
+    // 占用唯一的worker，取出的任务需要在任务池的队列中等待
+    release := make(chan struct{})
+    block := make(chan struct{})
+    if err := p.Submit(&BlockJob{Started: block, Release: release}); err != nil {
+        t.Fatal(err)
+    }
+    <-block
+    if _, err := q.Enqueue("lease", nil); err != nil {
+        t.Fatal(err)
+    }
+    q.Start()
+    defer q.Stop()
+    waitDurable(t, q, func(s DurableStats) bool { return s.Inflight == 1 })
+    time.Sleep(time.Millisecond * 300)
+    close(release)
+    // ctx的截止时间为取出任务时的可见性超时时间，而不是从开始执行时计算
+    if d := (<-deadlines).Sub(<-started); d > time.Millisecond*800 {
+        t.Fatalf("handler deadline should follow the lease, got %s left", d)
+    }
+}
+
+func TestDurableQueueQueueFull(t *testing.T) {
+    p := NewWorkerPool(1, 1, WithRejectPolicy(RejectDropNewest))
+    p.Run()
+    defer p.Stop()
+    registry := NewRegistry()
+    var done int32
+    registry.Register("full", func(ctx context.Context, payload []byte) error {
+        atomic.AddInt32(&done, 1)
+        return nil
+    })
+    release := make(chan struct{})
+    fillPool(t, p, release)
+    q := NewDurableQueue(p, NewMemoryDurableStore(), "full", registry,
+        WithVisibilityTimeout(time.Millisecond*50),
+        WithPollInterval(time.Millisecond*5))
+    if _, err := q.Enqueue("full", nil); err != nil {
+        t.Fatal(err)
+    }
+    q.Start()
+    defer q.Stop()
+    // 任务池的队列已满时继续轮询，队列空闲后取出超时重新投递的任务
+    time.Sleep(time.Millisecond * 100)
+    close(release)
+    waitDurable(t, q, func(s DurableStats) bool {
+        return atomic.LoadInt32(&done) == 1 && s.Ready == 0 && s.Inflight == 0
+    })
+}
+
+func TestDurableQueueLostLease(t *testing.T) {
+    p := NewWorkerPool(2, 10)
+    p.Run()
+    defer p.Stop()
+    registry := NewRegistry()
+    var calls int32
+    registry.Register("slow", func(ctx context.Context, payload []byte) error {
+        // 第一次执行超过可见性超时，任务被重新投递后才确认
+        if atomic.AddInt32(&calls, 1) == 1 {
+            time.Sleep(time.Millisecond * 150)
+        }
+        return nil
+    })
+    q := NewDurableQueue(p, NewMemoryDurableStore(), "slow", registry,
+        WithVisibilityTimeout(time.Millisecond*50),
+        WithPollInterval(time.Millisecond*5))
+    if _, err := q.Enqueue("slow", nil); err != nil {
+        t.Fatal(err)
+    }
+    q.Start()
+    defer q.Stop()
+    s := waitDurable(t, q, func(s DurableStats) bool { return s.Lost == 1 && s.Inflight == 0 })
+    if n := atomic.LoadInt32(&calls); n != 2 || s.Dead != 0 {
+        t.Fatalf("expect redelivery to be acked, got %d calls, %+v", n, s)
+    }
+}
+
+// testDurableStore 测试DurableStore的投递、重新投递以及确认
+func testDurableStore(t *testing.T, s DurableStore) {
+    if err := s.Push("q", "1", []byte("a"), 0, 0); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Push("q", "2", []byte("b"), 100, 0); err != nil {
+        t.Fatal(err)
+    }
+    first, err := s.Pop("q", 10, 50)
+    if err != nil || first == nil || first.ID != "1" || first.Attempts != 1 || first.VisibleUntil != 50 {
+        t.Fatalf("unexpected message: %+v, %v", first, err)
+    }
+    // 延迟消息未到期
+    if msg, _ := s.Pop("q", 20, 70); msg != nil {
+        t.Fatalf("unexpected message: %+v", msg)
+    }
+    // 超时未确认的消息优先重新投递
+    second, _ := s.Pop("q", 120, 200)
+    if second == nil || second.ID != "1" || second.Attempts != 2 || string(second.Data) != "a" {
+        t.Fatalf("unexpected message: %+v", second)
+    }
+    // 重新投递后之前的投递不能再确认、重试或者写入死信列表
+    if ok, err := s.Ack("q", first.ID, first.Attempts); err != nil || ok {
+        t.Fatalf("stale delivery should not be acked: %v, %v", ok, err)
+    }
+    if ok, _ := s.Retry("q", first.ID, first.Attempts, []byte("x"), 0); ok {
+        t.Fatal("stale delivery should not be retried")
+    }
+    if ok, _ := s.Bury("q", first.ID, first.Attempts, []byte("x")); ok {
+        t.Fatal("stale delivery should not be buried")
+    }
+    if ok, _ := s.Ack("q", "2", 1); ok {
+        t.Fatal("message not in flight should not be acked")
+    }
+    if ok, err := s.Retry("q", second.ID, second.Attempts, []byte("c"), 150); err != nil || !ok {
+        t.Fatalf("message should be retried: %v, %v", ok, err)
+    }
+    third, _ := s.Pop("q", 150, 250)
+    if third == nil || third.ID != "2" {
+        t.Fatalf("unexpected message: %+v", third)
+    }
+    if ok, err := s.Ack("q", third.ID, third.Attempts); err != nil || !ok {
+        t.Fatalf("message should be acked: %v, %v", ok, err)
+    }
+    // 重试的消息使用更新后的数据
+    fourth, _ := s.Pop("q", 150, 250)
+    if fourth == nil || fourth.ID != "1" || fourth.Attempts != 3 || string(fourth.Data) != "c" {
+        t.Fatalf("unexpected message: %+v", fourth)
+    }
+    ready, delayed, inflight, dead, err := s.Stats("q")
+    if err != nil || ready != 0 || delayed != 0 || inflight != 1 || dead != 0 {
+        t.Fatalf("unexpected stats: %d %d %d %d, %v", ready, delayed, inflight, dead, err)
+    }
+    if ok, err := s.Bury("q", fourth.ID, fourth.Attempts, []byte("dead")); err != nil || !ok {
+        t.Fatalf("message should be buried: %v, %v", ok, err)
+    }
+    letters, err := s.DeadLetters("q", 10)
+    if err != nil || len(letters) != 1 || string(letters[0]) != "dead" {
+        t.Fatalf("unexpected dead letters: %q, %v", letters, err)
+    }
+    if msg, _ := s.Pop("q", 1000, 2000); msg != nil {
+        t.Fatalf("unexpected message: %+v", msg)
+    }
+}
+
+func TestMemoryDurableStore(t *testing.T) {
+    testDurableStore(t, NewMemoryDurableStore())
+}
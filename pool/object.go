@@ -0,0 +1,300 @@
+package pool
+
+import (
+    "container/list"
+    "context"
+    "errors"
+    "sync"
+    "time"
+)
+
+// ErrObjectPoolClosed 对象池已经关闭
+var ErrObjectPoolClosed = errors.New("pool: object pool is closed")
+
+// objectOptions 对象池的可选参数
+type objectOptions struct {
+    maxIdle       int           // 最大空闲对象数，小于等于0表示不保留空闲对象
+    maxActive     int           // 最多同时存在的对象数（包括借出和空闲的），小于等于0表示不限制
+    idleTimeout   time.Duration // 空闲对象的最长保留时间，小于等于0表示不淘汰
+    evictInterval time.Duration // 检查空闲对象的间隔
+}
+
+// ObjectOption 设置对象池的可选参数
+type ObjectOption func(o *objectOptions)
+
+// WithMaxIdle 设置最大空闲对象数，归还时超过该数量的对象会被销毁，默认为8
+func WithMaxIdle(n int) ObjectOption {
+    return func(o *objectOptions) {
+        o.maxIdle = n
+    }
+}
+
+// WithMaxActive 设置最多同时存在的对象数（包括借出和空闲的），达到上限时Borrow会等待对象归还，默认不限制
+func WithMaxActive(n int) ObjectOption {
+    return func(o *objectOptions) {
+        o.maxActive = n
+    }
+}
+
+// WithIdleEviction 设置空闲对象的最长保留时间，每隔interval检查一次并销毁超时的空闲对象
+// interval小于等于0时使用idleTimeout的一半
+func WithIdleEviction(idleTimeout, interval time.Duration) ObjectOption {
+    return func(o *objectOptions) {
+        o.idleTimeout = idleTimeout
+        o.evictInterval = interval
+    }
+}
+
+// ObjectPoolConfig 对象池中对象的创建、检查、重置以及销毁方法，New为必须的，其它方法为nil时不调用
+type ObjectPoolConfig[T any] struct {
+    New      func(ctx context.Context) (T, error) // 创建新的对象
+    Validate func(obj T) bool                     // 借出对象前的检查方法，返回false的对象会被销毁，然后重新获取对象
+    Reset    func(obj T) error                    // 归还对象时的重置方法，返回错误的对象会被销毁
+    Destroy  func(obj T)                          // 销毁对象的方法，如关闭连接
+}
+
+// ObjectStats 对象池的运行状态
+type ObjectStats struct {
+    Active             int   // 已经借出的对象数
+    Idle               int   // 空闲的对象数
+    Created            int64 // 累计创建的对象数
+    Destroyed          int64 // 累计销毁的对象数
+    Borrowed           int64 // 累计借出的次数
+    Waited             int64 // 因为达到最大对象数而等待的次数
+    ValidationFailures int64 // 借出前检查失败的次数
+}
+
+// idleObject 空闲对象
+type idleObject[T any] struct {
+    obj   T
+    since time.Time // 归还的时间
+}
+
+// ObjectPool 可复用对象（如缓冲区、解析器、客户端连接）的对象池
+type ObjectPool[T any] struct {
+    factory  func(ctx context.Context) (T, error)
+    opts     *objectOptions
+    validate func(obj T) bool
+    reset    func(obj T) error
+    destroy  func(obj T)
+
+    mu       sync.Mutex
+    idle     *list.List // 空闲对象，最近归还的在尾部
+    total    int        // 借出和空闲的对象数，包括正在创建的对象
+    stats    ObjectStats
+    released chan struct{} // 归还或者销毁对象时关闭并重新创建，用于唤醒等待的Borrow
+    closed   bool
+    quit     chan struct{}
+}
+
+// NewObjectPool 创建对象池，factory用于创建新的对象，需要检查、重置或者销毁对象时使用NewObjectPoolWithConfig
+func NewObjectPool[T any](factory func(ctx context.Context) (T, error), opts ...ObjectOption) *ObjectPool[T] {
+    return NewObjectPoolWithConfig(ObjectPoolConfig[T]{New: factory}, opts...)
+}
+
+// NewObjectPoolWithConfig 使用cfg中的方法创建对象池
+func NewObjectPoolWithConfig[T any](cfg ObjectPoolConfig[T], opts ...ObjectOption) *ObjectPool[T] {
+    o := &objectOptions{
+        maxIdle: 8,
+    }
+    for _, opt := range opts {
+        if opt != nil {
+            opt(o)
+        }
+    }
+    p := &ObjectPool[T]{
+        factory:  cfg.New,
+        opts:     o,
+        validate: cfg.Validate,
+        reset:    cfg.Reset,
+        destroy:  cfg.Destroy,
+        idle:     list.New(),
+        released: make(chan struct{}),
+        quit:     make(chan struct{}),
+    }
+    if o.idleTimeout > 0 {
+        interval := o.evictInterval
+        if interval <= 0 {
+            interval = o.idleTimeout / 2
+        }
+        go p.evictLoop(interval)
+    }
+    return p
+}
+
+// notify 唤醒等待的Borrow，调用方需持有锁
+func (p *ObjectPool[T]) notify() {
+    close(p.released)
+    p.released = make(chan struct{})
+}
+
+// destroyObjects 销毁对象，调用方不能持有锁
+func (p *ObjectPool[T]) destroyObjects(objs ...T) {
+    if p.destroy == nil {
+        return
+    }
+    for _, obj := range objs {
+        p.destroy(obj)
+    }
+}
+
+// Borrow 借出对象，优先使用最近归还的空闲对象，没有空闲对象时创建新的对象
+// 达到最大对象数时等待对象归还，ctx结束时返回ctx.Err()，对象池关闭后返回ErrObjectPoolClosed
+func (p *ObjectPool[T]) Borrow(ctx context.Context) (T, error) {
+    var zero T
+    waited := false
+    for {
+        p.mu.Lock()
+        if p.closed {
+            p.mu.Unlock()
+            return zero, ErrObjectPoolClosed
+        }
+        if e := p.idle.Back(); e != nil {
+            obj := p.idle.Remove(e).(*idleObject[T]).obj
+            p.stats.Active++
+            p.mu.Unlock()
+            if p.validate != nil && !p.validate(obj) {
+                p.mu.Lock()
+                p.stats.ValidationFailures++
+                p.mu.Unlock()
+                p.discard(obj)
+                continue
+            }
+            p.mu.Lock()
+            p.stats.Borrowed++
+            p.mu.Unlock()
+            return obj, nil
+        }
+        if p.opts.maxActive <= 0 || p.total < p.opts.maxActive {
+            p.total++
+            p.stats.Active++
+            p.mu.Unlock()
+            obj, err := p.factory(ctx)
+            p.mu.Lock()
+            if err != nil {
+                p.total--
+                p.stats.Active--
+                p.notify()
+                p.mu.Unlock()
+                return zero, err
+            }
+            p.stats.Created++
+            p.stats.Borrowed++
+            p.mu.Unlock()
+            return obj, nil
+        }
+        if !waited {
+            waited = true
+            p.stats.Waited++
+        }
+        released := p.released
+        p.mu.Unlock()
+        select {
+        case <-released:
+        case <-ctx.Done():
+            return zero, ctx.Err()
+        }
+    }
+}
+
+// Return 归还对象，重置失败、超过最大空闲对象数或者对象池已经关闭时销毁对象
+func (p *ObjectPool[T]) Return(obj T) {
+    if p.reset != nil {
+        if err := p.reset(obj); err != nil {
+            p.discard(obj)
+            return
+        }
+    }
+    p.mu.Lock()
+    if p.closed || p.idle.Len() >= p.opts.maxIdle {
+        p.mu.Unlock()
+        p.discard(obj)
+        return
+    }
+    p.stats.Active--
+    p.idle.PushBack(&idleObject[T]{obj: obj, since: time.Now()})
+    p.notify()
+    p.mu.Unlock()
+}
+
+// Invalidate 销毁借出的对象，用于对象已经不可用（如连接断开）的情形
+func (p *ObjectPool[T]) Invalidate(obj T) {
+    p.discard(obj)
+}
+
+// discard 销毁借出的对象并释放名额
+func (p *ObjectPool[T]) discard(obj T) {
+    p.mu.Lock()
+    p.total--
+    p.stats.Active--
+    p.stats.Destroyed++
+    p.notify()
+    p.mu.Unlock()
+    p.destroyObjects(obj)
+}
+
+// evictLoop 定期销毁超时的空闲对象
+func (p *ObjectPool[T]) evictLoop(interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            p.evict(time.Now().Add(-p.opts.idleTimeout))
+        case <-p.quit:
+            return
+        }
+    }
+}
+
+// evict 销毁在deadline之前归还的空闲对象
+func (p *ObjectPool[T]) evict(deadline time.Time) {
+    var expired []T
+    p.mu.Lock()
+    // 最早归还的对象在头部
+    for e := p.idle.Front(); e != nil; e = p.idle.Front() {
+        item := e.Value.(*idleObject[T])
+        if item.since.After(deadline) {
+            break
+        }
+        p.idle.Remove(e)
+        expired = append(expired, item.obj)
+    }
+    if len(expired) > 0 {
+        p.total -= len(expired)
+        p.stats.Destroyed += int64(len(expired))
+        p.notify()
+    }
+    p.mu.Unlock()
+    p.destroyObjects(expired...)
+}
+
+// Stats 返回对象池的运行状态
+func (p *ObjectPool[T]) Stats() ObjectStats {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    s := p.stats
+    s.Idle = p.idle.Len()
+    return s
+}
+
+// Close 关闭对象池并销毁所有空闲对象，之后归还的对象会被直接销毁
+func (p *ObjectPool[T]) Close() {
+    p.mu.Lock()
+    if p.closed {
+        p.mu.Unlock()
+        return
+    }
+    p.closed = true
+    close(p.quit)
+    var objs []T
+    for e := p.idle.Front(); e != nil; e = e.Next() {
+        objs = append(objs, e.Value.(*idleObject[T]).obj)
+    }
+    p.idle.Init()
+    p.total -= len(objs)
+    p.stats.Destroyed += int64(len(objs))
+    p.notify()
+    p.mu.Unlock()
+    p.destroyObjects(objs...)
+}
@@ -0,0 +1,137 @@
+package pool
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+// 定义一个模拟连接的对象
+type fakeConn struct {
+    id     int
+    broken bool
+    closed bool
+}
+
+func TestObjectPool(t *testing.T) {
+    created := 0
+    var destroyed []*fakeConn
+    p := NewObjectPoolWithConfig(ObjectPoolConfig[*fakeConn]{
+        New: func(ctx context.Context) (*fakeConn, error) {
+            created++
+            return &fakeConn{id: created}, nil
+        },
+        Validate: func(c *fakeConn) bool { return !c.broken },
+        Destroy: func(c *fakeConn) {
+            c.closed = true
+            destroyed = append(destroyed, c)
+        },
+    }, WithMaxIdle(1), WithMaxActive(2))
+
+    ctx := context.Background()
+    c1, _ := p.Borrow(ctx)
+    c2, _ := p.Borrow(ctx)
+    // 达到最大对象数时等待归还
+    timeout, cancel := context.WithTimeout(ctx, time.Millisecond*20)
+    defer cancel()
+    if _, err := p.Borrow(timeout); err != context.DeadlineExceeded {
+        t.Fatalf("expect deadline exceeded, got %v", err)
+    }
+    go func() {
+        time.Sleep(time.Millisecond * 20)
+        p.Return(c1)
+    }()
+    c3, err := p.Borrow(ctx)
+    if err != nil || c3 != c1 {
+        t.Fatalf("should reuse returned object: %v, %v", c3, err)
+    }
+
+    // 超过最大空闲数的对象被销毁
+    p.Return(c2)
+    p.Return(c3)
+    if len(destroyed) != 1 || !destroyed[0].closed {
+        t.Fatalf("unexpected destroyed objects: %v", destroyed)
+    }
+
+    // 检查失败的对象被销毁并创建新的对象
+    c2.broken = true
+    c4, _ := p.Borrow(ctx)
+    if c4.id != 3 || len(destroyed) != 2 {
+        t.Fatalf("broken object should be replaced, got %d", c4.id)
+    }
+    s := p.Stats()
+    if s.Active != 1 || s.Idle != 0 || s.Created != 3 || s.Destroyed != 2 || s.Borrowed != 4 || s.Waited != 2 || s.ValidationFailures != 1 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+
+    p.Invalidate(c4)
+    p.Close()
+    if _, err = p.Borrow(ctx); err != ErrObjectPoolClosed {
+        t.Fatalf("expect ErrObjectPoolClosed, got %v", err)
+    }
+}
+
+func TestObjectPoolReset(t *testing.T) {
+    p := NewObjectPoolWithConfig(ObjectPoolConfig[*bytes.Buffer]{
+        New: func(ctx context.Context) (*bytes.Buffer, error) {
+            return new(bytes.Buffer), nil
+        },
+        Reset: func(b *bytes.Buffer) error {
+            if b.Cap() > 1024 {
+                return errors.New("buffer too large")
+            }
+            b.Reset()
+            return nil
+        },
+    })
+    defer p.Close()
+
+    ctx := context.Background()
+    b, _ := p.Borrow(ctx)
+    b.WriteString("hello")
+    p.Return(b)
+    b2, _ := p.Borrow(ctx)
+    if b2 != b || b2.Len() != 0 {
+        t.Fatal("returned buffer should be reset and reused")
+    }
+    b2.Write(make([]byte, 2048))
+    p.Return(b2)
+    if s := p.Stats(); s.Idle != 0 || s.Destroyed != 1 {
+        t.Fatalf("large buffer should be destroyed: %+v", s)
+    }
+
+    // 创建失败
+    failed := NewObjectPool(func(ctx context.Context) (int, error) {
+        return 0, errors.New("dial failed")
+    }, WithMaxActive(1))
+    if _, err := failed.Borrow(ctx); err == nil {
+        t.Fatal("factory error should be returned")
+    }
+    if s := failed.Stats(); s.Active != 0 || s.Created != 0 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+}
+
+func TestObjectPoolEviction(t *testing.T) {
+    p := NewObjectPool(func(ctx context.Context) (int, error) {
+        return 1, nil
+    }, WithMaxIdle(4), WithIdleEviction(time.Millisecond*30, time.Millisecond*10))
+    defer p.Close()
+    ctx := context.Background()
+    objs := make([]int, 3)
+    for i := range objs {
+        objs[i], _ = p.Borrow(ctx)
+    }
+    for _, obj := range objs {
+        p.Return(obj)
+    }
+    if s := p.Stats(); s.Idle != 3 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+    time.Sleep(time.Millisecond * 80)
+    if s := p.Stats(); s.Idle != 0 || s.Destroyed != 3 {
+        t.Fatalf("idle objects should be evicted: %+v", s)
+    }
+}
@@ -0,0 +1,139 @@
+package pool
+
+import (
+    "context"
+    "time"
+)
+
+// ShutdownPolicy 关闭任务池时对队列中尚未执行的任务的处理策略
+type ShutdownPolicy int
+
+const (
+    ShutdownDrain   ShutdownPolicy = iota // 执行完队列中的所有任务后再退出（默认）
+    ShutdownAbandon                       // 丢弃队列中的任务，只等待正在执行的任务
+)
+
+// RejectPolicy 任务队列已满时对新提交任务的处理策略
+type RejectPolicy int
+
+const (
+    RejectBlock      RejectPolicy = iota // 阻塞等待队列空闲（默认）
+    RejectDropNewest                     // 拒绝新提交的任务，返回ErrQueueFull
+    RejectDropOldest                     // 丢弃队列中最早的任务，接收新提交的任务
+    RejectCallerRuns                     // 在提交任务的goroutine中直接执行任务
+)
+
+// Priority 任务的优先级，数值越大越先执行，可以使用预定义的级别，也可以使用任意整数
+type Priority int
+
+const (
+    PriorityLow    Priority = -1
+    PriorityNormal Priority = 0 // 默认优先级
+    PriorityHigh   Priority = 1
+)
+
+// options 任务池的可选参数
+type options struct {
+    ctx            context.Context // 任务池的父context，结束时自动关闭任务池
+    shutdownPolicy ShutdownPolicy  // 关闭策略
+    panicHandler   PanicHandler    // 任务发生panic时的处理方法
+    rejectPolicy   RejectPolicy    // 队列已满时的处理策略
+    maxWorkers     int             // 最大worker数量，小于等于最小数量时不自动扩容
+    idleTimeout    time.Duration   // 超过最小数量的worker空闲多久后退出，小于等于0表示不退出
+}
+
+// defaultIdleTimeout 默认的worker空闲超时时间
+const defaultIdleTimeout = time.Minute
+
+// Option 设置任务池的可选参数
+type Option func(o *options)
+
+// newOptions 根据传入的option生成参数
+func newOptions(opts []Option) *options {
+    o := &options{
+        ctx:         context.Background(),
+        idleTimeout: defaultIdleTimeout,
+    }
+    for _, opt := range opts {
+        if opt != nil {
+            opt(o)
+        }
+    }
+    return o
+}
+
+// WithContext 设置任务池的父context，ctx结束时任务池自动关闭
+func WithContext(ctx context.Context) Option {
+    return func(o *options) {
+        if ctx != nil {
+            o.ctx = ctx
+        }
+    }
+}
+
+// WithShutdownPolicy 设置关闭任务池时对队列中任务的处理策略
+func WithShutdownPolicy(p ShutdownPolicy) Option {
+    return func(o *options) {
+        o.shutdownPolicy = p
+    }
+}
+
+// WithPanicHandler 设置任务发生panic时的处理方法，默认将panic信息以及调用栈输出到标准日志
+// 通过NewTask创建的任务发生panic时，会将*PanicError作为任务的结果，不会调用此方法
+func WithPanicHandler(h PanicHandler) Option {
+    return func(o *options) {
+        o.panicHandler = h
+    }
+}
+
+// WithRejectPolicy 设置任务队列已满时对新提交任务的处理策略
+// 被丢弃的任务如果是通过NewTask创建的，其Future会收到ErrQueueFull或者ErrJobDropped
+func WithRejectPolicy(p RejectPolicy) Option {
+    return func(o *options) {
+        o.rejectPolicy = p
+    }
+}
+
+// WithMaxWorkers 设置最大worker数量，队列中等待的任务多于空闲的worker时自动增加worker，直到达到n
+// NewWorkerPool中的workerSize为最小worker数量，默认不自动扩容
+func WithMaxWorkers(n int) Option {
+    return func(o *options) {
+        o.maxWorkers = n
+    }
+}
+
+// WithIdleTimeout 设置超过最小数量的worker空闲多久后退出，默认为1分钟，小于等于0表示不退出
+func WithIdleTimeout(d time.Duration) Option {
+    return func(o *options) {
+        o.idleTimeout = d
+    }
+}
+
+// SubmitOption 设置提交任务时的可选参数
+type SubmitOption func(item *jobItem)
+
+// newJobItem 根据传入的option生成队列中的任务
+func newJobItem(job Job, opts []SubmitOption) *jobItem {
+    item := &jobItem{job: job}
+    for _, opt := range opts {
+        if opt != nil {
+            opt(item)
+        }
+    }
+    return item
+}
+
+// WithJobPriority 设置任务的优先级，队列中优先级高的任务先执行，相同优先级的任务按提交顺序执行
+func WithJobPriority(p Priority) SubmitOption {
+    return func(item *jobItem) {
+        item.priority = p
+    }
+}
+
+// WithJobKey 设置任务的key，相同key的任务按提交顺序串行执行，不同key的任务并行执行
+// 等待前一个相同key的任务完成的任务同样占用队列容量，优先级只影响轮到该任务时与其它任务的执行先后
+func WithJobKey(key string) SubmitOption {
+    return func(item *jobItem) {
+        item.key = key
+    }
+}
@@ -0,0 +1,203 @@
+package pool
+
+import (
+    "context"
+    "sync"
+)
+
+// Group 限制并发数量的一组任务，任意任务返回错误后取消其余任务，Wait返回第一个错误
+type Group struct {
+    ctx     context.Context
+    cancel  context.CancelFunc
+    sem     chan struct{} // 并发控制，为nil时不限制
+    wg      sync.WaitGroup
+    errOnce sync.Once
+    err     error
+}
+
+// NewGroup 创建任务组，limit为最大并发数量，小于等于0表示不限制
+// 返回的ctx在任意任务返回错误、ctx结束或者Wait返回后被取消
+func NewGroup(ctx context.Context, limit int) (*Group, context.Context) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    ctx, cancel := context.WithCancel(ctx)
+    g := &Group{
+        ctx:    ctx,
+        cancel: cancel,
+    }
+    if limit > 0 {
+        g.sem = make(chan struct{}, limit)
+    }
+    return g, ctx
+}
+
+// fail 记录第一个错误并取消其余任务
+func (g *Group) fail(err error) {
+    g.errOnce.Do(func() {
+        g.err = err
+        g.cancel()
+    })
+}
+
+// Go 在新的goroutine中执行fn，达到并发数量时阻塞直到有任务完成
+// 任务组已经取消时不再执行fn，fn中发生的panic会作为*PanicError返回
+func (g *Group) Go(fn func(ctx context.Context) error) {
+    if g.sem != nil {
+        select {
+        case g.sem <- struct{}{}:
+        case <-g.ctx.Done():
+            g.fail(g.ctx.Err())
+            return
+        }
+    }
+    g.start(fn)
+}
+
+// TryGo 尝试执行fn，达到并发数量时不阻塞，直接返回false
+func (g *Group) TryGo(fn func(ctx context.Context) error) bool {
+    if g.sem != nil {
+        select {
+        case g.sem <- struct{}{}:
+        default:
+            return false
+        }
+    }
+    g.start(fn)
+    return true
+}
+
+// start 启动任务，调用方已经获取并发名额
+func (g *Group) start(fn func(ctx context.Context) error) {
+    g.wg.Add(1)
+    go func() {
+        defer func() {
+            if g.sem != nil {
+                <-g.sem
+            }
+            g.wg.Done()
+        }()
+        if err := g.ctx.Err(); err != nil {
+            g.fail(err)
+            return
+        }
+        if err := g.call(fn); err != nil {
+            g.fail(err)
+        }
+    }()
+}
+
+// call 执行fn，将panic转换为*PanicError
+func (g *Group) call(fn func(ctx context.Context) error) (err error) {
+    defer func() {
+        if v := recover(); v != nil {
+            err = newPanicError(v)
+        }
+    }()
+    return fn(g.ctx)
+}
+
+// Wait 等待所有任务完成，返回第一个错误
+func (g *Group) Wait() error {
+    g.wg.Wait()
+    g.cancel()
+    return g.err
+}
+
+// ResultGroup 收集返回值的任务组，返回值按照调用Go的顺序排列
+type ResultGroup[R any] struct {
+    *Group
+    mu      sync.Mutex
+    results []R
+}
+
+// NewResultGroup 创建收集返回值的任务组，limit为最大并发数量，小于等于0表示不限制
+func NewResultGroup[R any](ctx context.Context, limit int) (*ResultGroup[R], context.Context) {
+    g, ctx := NewGroup(ctx, limit)
+    return &ResultGroup[R]{Group: g}, ctx
+}
+
+// Go 在新的goroutine中执行fn，返回值保存在调用顺序对应的位置
+func (g *ResultGroup[R]) Go(fn func(ctx context.Context) (R, error)) {
+    g.Group.Go(g.wrap(fn))
+}
+
+// TryGo 尝试执行fn，达到并发数量时不阻塞，直接返回false，此时不会占用返回值的位置
+func (g *ResultGroup[R]) TryGo(fn func(ctx context.Context) (R, error)) bool {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    i := len(g.results)
+    ok := g.Group.TryGo(func(ctx context.Context) error {
+        r, err := fn(ctx)
+        g.set(i, r)
+        return err
+    })
+    if ok {
+        var zero R
+        g.results = append(g.results, zero)
+    }
+    return ok
+}
+
+// wrap 分配返回值的位置并包装fn
+func (g *ResultGroup[R]) wrap(fn func(ctx context.Context) (R, error)) func(ctx context.Context) error {
+    g.mu.Lock()
+    i := len(g.results)
+    var zero R
+    g.results = append(g.results, zero)
+    g.mu.Unlock()
+    return func(ctx context.Context) error {
+        r, err := fn(ctx)
+        g.set(i, r)
+        return err
+    }
+}
+
+// set 保存第i个返回值
+func (g *ResultGroup[R]) set(i int, r R) {
+    g.mu.Lock()
+    g.results[i] = r
+    g.mu.Unlock()
+}
+
+// Wait 等待所有任务完成，返回按调用顺序排列的返回值以及第一个错误，出错时返回值可能不完整
+func (g *ResultGroup[R]) Wait() ([]R, error) {
+    err := g.Group.Wait()
+    return g.results, err
+}
+
+// ParallelMap 使用最多limit个goroutine对items中的每一项执行fn，返回与items顺序一致的结果
+// 任意一项返回错误时取消其余项并返回第一个错误，limit小于等于0表示不限制
+func ParallelMap[T, R any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+    results := make([]R, len(items))
+    err := parallel(ctx, items, limit, func(ctx context.Context, i int, item T) error {
+        r, err := fn(ctx, item)
+        results[i] = r
+        return err
+    })
+    return results, err
+}
+
+// ParallelForEach 使用最多limit个goroutine对items中的每一项执行fn
+// 任意一项返回错误时取消其余项并返回第一个错误，limit小于等于0表示不限制
+func ParallelForEach[T any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) error) error {
+    return parallel(ctx, items, limit, func(ctx context.Context, _ int, item T) error {
+        return fn(ctx, item)
+    })
+}
+
+// parallel 并发处理items，fn中可以根据下标写入结果
+func parallel[T any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, i int, item T) error) error {
+    g, gctx := NewGroup(ctx, limit)
+    for i, item := range items {
+        if err := gctx.Err(); err != nil {
+            g.fail(err)
+            break
+        }
+        i, item := i, item
+        g.Go(func(ctx context.Context) error {
+            return fn(ctx, i, item)
+        })
+    }
+    return g.Wait()
+}
@@ -0,0 +1,113 @@
+package pool
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestParallelMap(t *testing.T) {
+    items := make([]int, 20)
+    for i := range items {
+        items[i] = i
+    }
+    var running, peak int32
+    results, err := ParallelMap(context.Background(), items, 3, func(ctx context.Context, item int) (string, error) {
+        n := atomic.AddInt32(&running, 1)
+        for {
+            p := atomic.LoadInt32(&peak)
+            if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+                break
+            }
+        }
+        time.Sleep(time.Millisecond * time.Duration(20-item))
+        atomic.AddInt32(&running, -1)
+        return fmt.Sprint(item * item), nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i, r := range results {
+        if r != fmt.Sprint(i*i) {
+            t.Fatalf("unexpected result at %d: %s", i, r)
+        }
+    }
+    if peak > 3 {
+        t.Fatalf("concurrency should not exceed 3, got %d", peak)
+    }
+}
+
+func TestParallelForEachError(t *testing.T) {
+    bizErr := errors.New("biz error")
+    var executed int32
+    err := ParallelForEach(context.Background(), make([]int, 100), 2, func(ctx context.Context, item int) error {
+        if atomic.AddInt32(&executed, 1) == 3 {
+            return bizErr
+        }
+        select {
+        case <-ctx.Done():
+        case <-time.After(time.Millisecond * 5):
+        }
+        return nil
+    })
+    if err != bizErr {
+        t.Fatalf("expect biz error, got %v", err)
+    }
+    // 出错后不再执行剩余的项
+    if executed >= 100 {
+        t.Fatalf("remaining items should be skipped, executed %d", executed)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if err = ParallelForEach(ctx, []int{1, 2}, 1, func(ctx context.Context, item int) error {
+        return nil
+    }); err != context.Canceled {
+        t.Fatalf("expect context canceled, got %v", err)
+    }
+}
+
+func TestGroup(t *testing.T) {
+    g, ctx := NewGroup(context.Background(), 1)
+    release := make(chan struct{})
+    g.Go(func(ctx context.Context) error {
+        <-release
+        return nil
+    })
+    if g.TryGo(func(ctx context.Context) error { return nil }) {
+        t.Fatal("TryGo should fail when limit is reached")
+    }
+    close(release)
+    g.Go(func(ctx context.Context) error {
+        panic("boom")
+    })
+    err := g.Wait()
+    var pe *PanicError
+    if !errors.As(err, &pe) || pe.Value != "boom" {
+        t.Fatalf("expect panic error, got %v", err)
+    }
+    if ctx.Err() == nil {
+        t.Fatal("group context should be canceled")
+    }
+}
+
+func TestResultGroup(t *testing.T) {
+    g, _ := NewResultGroup[int](context.Background(), 2)
+    for i := 0; i < 5; i++ {
+        i := i
+        g.Go(func(ctx context.Context) (int, error) {
+            time.Sleep(time.Millisecond * time.Duration(5-i))
+            return i * 10, nil
+        })
+    }
+    results, err := g.Wait()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fmt.Sprint(results) != "[0 10 20 30 40]" {
+        t.Fatalf("unexpected results: %v", results)
+    }
+}
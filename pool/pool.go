@@ -1,5 +1,26 @@
 package pool
 
+import (
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+var (
+    // ErrPoolClosed 任务池已经关闭，不再接收任务
+    ErrPoolClosed = errors.New("pool: worker pool is closed")
+    // ErrQueueFull 任务队列已满，任务被拒绝
+    ErrQueueFull = errors.New("pool: job queue is full")
+    // ErrSubmitTimeout 等待任务队列空闲超时
+    ErrSubmitTimeout = errors.New("pool: submit timeout")
+    // ErrInvalidSize worker数量不合法
+    ErrInvalidSize = errors.New("pool: invalid worker size")
+    // ErrJobDropped 任务在队列中等待时被更新的任务挤出（RejectDropOldest）
+    ErrJobDropped = errors.New("pool: job dropped from queue")
+)
+
 // --------------------------- Job ---------------------
 type Job interface {
     Do()
@@ -8,52 +29,409 @@ type Job interface {
 // --------------------------- Worker ---------------------
 type Worker struct {
     JobQueue chan Job
+    quit     chan struct{}
+    onPanic  PanicHandler // 任务发生panic时的处理方法，为nil时输出到标准日志
 }
 
 func NewWorker() Worker {
-    return Worker{JobQueue: make(chan Job)}
+    return Worker{
+        JobQueue: make(chan Job),
+        quit:     make(chan struct{}),
+    }
 }
+
+// Run 启动worker，循环地将自己的任务通道注册到wq中，并执行收到的任务，直到调用Stop
+// 任务中发生的panic会被恢复，不会导致worker退出
 func (w Worker) Run(wq chan chan Job) {
-    go func() {
-        for {
-            wq <- w.JobQueue
-            select {
-            case job := <-w.JobQueue:
-                job.Do()
-            }
+    go w.loop(wq, nil)
+}
+
+// Stop 通知worker退出，正在执行的任务会继续执行完成，只能调用一次
+func (w Worker) Stop() {
+    close(w.quit)
+}
+
+// loop 执行任务的循环，退出时通知wg
+func (w Worker) loop(wq chan chan Job, wg *sync.WaitGroup) {
+    if wg != nil {
+        defer wg.Done()
+    }
+    for {
+        select {
+        case wq <- w.JobQueue:
+        case <-w.quit:
+            return
         }
-    }()
+        select {
+        case job := <-w.JobQueue:
+            safeDo(job, w.onPanic)
+        case <-w.quit:
+            return
+        }
+    }
 }
 
 // --------------------------- WorkerPool ---------------------
 type WorkerPool struct {
-    size        int
-    JobQueue    chan Job
+    size     int
+    JobQueue chan Job // 兼容旧版本的任务入口，写入的任务等同于调用Submit，建议直接使用Submit系列方法；关闭后写入的任务以ErrPoolClosed拒绝
+    // Deprecated: 任务池的worker直接从内部的有界队列中获取任务，不再使用此字段
     WorkerQueue chan chan Job
+
+    opts      *options
+    queue     *jobQueue
+    ctx       context.Context
+    cancel    context.CancelFunc
+    mu        sync.Mutex
+    started   bool
+    closed    bool
+    stopping  bool  // 队列已关闭，不再创建worker
+    maxSize   int   // 最大worker数量
+    workers   int   // 运行中的worker数量
+    idle      int64 // 等待任务的worker数量
+    active    int64 // 正在执行任务的worker数量
+    completed int64 // 已执行的任务数量
+    rejected  int64 // 被拒绝或者丢弃的任务数量
+    sched     *scheduler // 定时任务，第一次添加定时任务时创建
+    wg        sync.WaitGroup
+    quit      chan struct{} // 开始关闭时关闭
+    fed       chan struct{} // JobQueue中的任务全部转入队列后关闭
+    done      chan struct{} // 所有worker退出后关闭
 }
 
-func NewWorkerPool(workerSize, queueSize int) *WorkerPool {
+// Stats 任务池的运行状态
+type Stats struct {
+    Workers   int   // 运行中的worker数量
+    Active    int   // 正在执行任务的worker数量
+    Idle      int   // 等待任务的worker数量
+    Queued    int   // 等待执行的任务数量，包括等待相同key的任务执行完成的任务
+    Completed int64 // 已执行的任务数量（包括发生panic的任务）
+    Rejected  int64 // 被拒绝或者丢弃的任务数量
+}
+
+// NewWorkerPool 创建任务池，workerSize为worker的数量，queueSize为任务队列的容量，小于等于0表示不限制
+// opts为可选参数，如WithRejectPolicy用于设置队列已满时的处理策略，WithShutdownPolicy用于设置关闭时如何处理未执行的任务，
+// WithMaxWorkers用于设置自动扩容的最大worker数量，此时workerSize为最小worker数量
+func NewWorkerPool(workerSize, queueSize int, opts ...Option) *WorkerPool {
+    o := newOptions(opts)
+    ctx, cancel := context.WithCancel(o.ctx)
+    maxSize := o.maxWorkers
+    if maxSize < workerSize {
+        maxSize = workerSize
+    }
     return &WorkerPool{
         size:        workerSize,
         JobQueue:    make(chan Job),
-        WorkerQueue: make(chan chan Job, queueSize),
+        WorkerQueue: make(chan chan Job, workerSize),
+        opts:        o,
+        queue:       newJobQueue(queueSize),
+        ctx:         ctx,
+        cancel:      cancel,
+        maxSize:     maxSize,
+        quit:        make(chan struct{}),
+        fed:         make(chan struct{}),
+        done:        make(chan struct{}),
     }
 }
 
+// Run 启动worker，在Run之前提交的任务会在启动后执行
 func (wp *WorkerPool) Run() {
+    wp.mu.Lock()
+    defer wp.mu.Unlock()
+    if wp.started || wp.closed {
+        return
+    }
+    wp.started = true
     // 初始化worker
-    for i := 0; i < wp.size; i++ {
-        worker := NewWorker()
-        worker.Run(wp.WorkerQueue)
+    for wp.workers < wp.size {
+        wp.spawn()
     }
-    // 循环获取可用的worker,往worker中写job
+    // 将写入JobQueue的任务转入队列
+    go wp.feed()
+    // 通过WithContext传入的ctx结束时关闭任务池
     go func() {
-        for {
-            select {
-            case job := <-wp.JobQueue:
-                worker := <-wp.WorkerQueue
-                worker <- job
+        select {
+        case <-wp.ctx.Done():
+            wp.Stop()
+        case <-wp.quit:
+        }
+    }()
+}
+
+// spawn 启动一个worker，调用方需持有锁
+func (wp *WorkerPool) spawn() {
+    wp.workers++
+    wp.wg.Add(1)
+    go wp.work()
+}
+
+// grow 队列中等待的任务多于空闲的worker并且未达到最大数量时增加一个worker
+func (wp *WorkerPool) grow() {
+    wp.mu.Lock()
+    defer wp.mu.Unlock()
+    if !wp.started || wp.stopping || wp.workers >= wp.maxSize {
+        return
+    }
+    if int64(wp.queue.ready()) > atomic.LoadInt64(&wp.idle) {
+        wp.spawn()
+    }
+}
+
+// retire 判断worker是否需要退出，需要退出时减少worker计数
+// 超过最大数量时退出；idle为true（空闲超时）时，超过最小数量并且队列为空也退出
+func (wp *WorkerPool) retire(idle bool) bool {
+    wp.mu.Lock()
+    defer wp.mu.Unlock()
+    if wp.workers > wp.maxSize || (idle && wp.workers > wp.size && wp.queue.ready() == 0) {
+        wp.workers--
+        return true
+    }
+    return false
+}
+
+// work worker的执行循环，队列关闭并且没有剩余任务、超过最大数量或者空闲超时时退出
+func (wp *WorkerPool) work() {
+    defer wp.wg.Done()
+    for {
+        if wp.retire(false) {
+            return
+        }
+        atomic.AddInt64(&wp.idle, 1)
+        item, state := wp.queue.pop(wp.opts.idleTimeout)
+        atomic.AddInt64(&wp.idle, -1)
+        switch state {
+        case popClosed:
+            wp.mu.Lock()
+            wp.workers--
+            wp.mu.Unlock()
+            return
+        case popTimeout:
+            if wp.retire(true) {
+                return
             }
+            continue
+        case popWakeup:
+            continue
         }
+        wp.run(item)
+    }
+}
+
+// run 执行任务，带有key的任务执行完成后通知队列，使相同key的下一个任务可以被取出
+func (wp *WorkerPool) run(item *jobItem) {
+    if item.key != "" {
+        defer wp.queue.done(item.key)
+    }
+    wp.do(item.job)
+}
+
+// do 执行任务并更新统计
+func (wp *WorkerPool) do(job Job) {
+    atomic.AddInt64(&wp.active, 1)
+    defer func() {
+        atomic.AddInt64(&wp.active, -1)
+        atomic.AddInt64(&wp.completed, 1)
     }()
+    safeDo(job, wp.opts.panicHandler)
+}
+
+// reject 通知任务未被执行并更新统计
+func (wp *WorkerPool) reject(job Job, err error) {
+    atomic.AddInt64(&wp.rejected, 1)
+    rejectJob(job, err)
+}
+
+// Resize 调整worker的数量范围，minWorkers为常驻的worker数量，maxWorkers为自动扩容的上限
+// 不足minWorkers时立即增加worker，超过maxWorkers的worker在当前任务完成后退出，超过minWorkers的worker在空闲超时后退出
+func (wp *WorkerPool) Resize(minWorkers, maxWorkers int) error {
+    if minWorkers < 0 || maxWorkers <= 0 || maxWorkers < minWorkers {
+        return ErrInvalidSize
+    }
+    wp.mu.Lock()
+    defer wp.mu.Unlock()
+    if wp.closed {
+        return ErrPoolClosed
+    }
+    shrink := maxWorkers < wp.maxSize
+    wp.size = minWorkers
+    wp.maxSize = maxWorkers
+    if wp.started {
+        for wp.workers < wp.size {
+            wp.spawn()
+        }
+        // 队列中仍有任务时按需补充worker
+        for wp.workers < wp.maxSize && int64(wp.queue.ready()) > atomic.LoadInt64(&wp.idle) {
+            wp.spawn()
+        }
+        if shrink {
+            wp.queue.wakeup()
+        }
+    }
+    return nil
+}
+
+// Stats 返回任务池的运行状态
+func (wp *WorkerPool) Stats() Stats {
+    wp.mu.Lock()
+    workers := wp.workers
+    wp.mu.Unlock()
+    return Stats{
+        Workers:   workers,
+        Active:    int(atomic.LoadInt64(&wp.active)),
+        Idle:      int(atomic.LoadInt64(&wp.idle)),
+        Queued:    wp.queue.len(),
+        Completed: atomic.LoadInt64(&wp.completed),
+        Rejected:  atomic.LoadInt64(&wp.rejected),
+    }
+}
+
+// feed 将写入JobQueue的任务转入队列，关闭时转入已经写入的任务，之后拒绝继续写入的任务
+func (wp *WorkerPool) feed() {
+    for {
+        select {
+        case job := <-wp.JobQueue:
+            _ = wp.Submit(job)
+        case <-wp.quit:
+            for drained := false; !drained; {
+                select {
+                case job := <-wp.JobQueue:
+                    _ = wp.Submit(job)
+                default:
+                    drained = true
+                }
+            }
+            close(wp.fed)
+            wp.discard()
+            return
+        }
+    }
+}
+
+// discard 任务池关闭后继续接收写入JobQueue的任务并以ErrPoolClosed拒绝，避免写入方永久阻塞
+func (wp *WorkerPool) discard() {
+    for job := range wp.JobQueue {
+        wp.reject(job, ErrPoolClosed)
+    }
+}
+
+// submit 提交任务，wait为false时不会阻塞等待队列空闲，任务未被接收时会通知任务结果
+func (wp *WorkerPool) submit(ctx context.Context, job Job, wait bool, opts []SubmitOption) error {
+    item := newJobItem(job, opts)
+    // 队列已满时先尝试扩容，写入后再根据等待的任务数扩容
+    wp.grow()
+    callerItem, dropped, err := wp.queue.push(ctx, item, wp.opts.rejectPolicy, wait)
+    if err != nil {
+        wp.reject(job, err)
+        return err
+    }
+    wp.grow()
+    if dropped != nil {
+        wp.reject(dropped.job, ErrJobDropped)
+    }
+    if callerItem != nil {
+        wp.run(callerItem)
+    }
+    return nil
+}
+
+// Submit 提交任务，队列已满时按拒绝策略处理，默认阻塞直到队列空闲
+// 任务池关闭后返回ErrPoolClosed，队列已满并且策略为RejectDropNewest时返回ErrQueueFull
+// opts用于设置任务的优先级（WithJobPriority）以及串行执行的key（WithJobKey）
+func (wp *WorkerPool) Submit(job Job, opts ...SubmitOption) error {
+    return wp.submit(context.Background(), job, true, opts)
+}
+
+// SubmitContext 提交任务，阻塞等待队列空闲时ctx结束则返回ctx.Err()
+func (wp *WorkerPool) SubmitContext(ctx context.Context, job Job, opts ...SubmitOption) error {
+    return wp.submit(ctx, job, true, opts)
+}
+
+// TrySubmit 尝试提交任务，不会阻塞，队列已满并且策略为RejectBlock时立即返回ErrQueueFull
+func (wp *WorkerPool) TrySubmit(job Job, opts ...SubmitOption) error {
+    return wp.submit(context.Background(), job, false, opts)
+}
+
+// SubmitWithTimeout 提交任务，阻塞等待队列空闲的时间超过timeout时返回ErrSubmitTimeout
+func (wp *WorkerPool) SubmitWithTimeout(job Job, timeout time.Duration, opts ...SubmitOption) error {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    err := wp.submit(ctx, job, true, opts)
+    if err == context.DeadlineExceeded {
+        return ErrSubmitTimeout
+    }
+    return err
+}
+
+// SubmitTask 将带有返回值的方法提交到任务池，返回用于等待执行结果的Future
+func SubmitTask[T any](wp *WorkerPool, fn func() (T, error), opts ...SubmitOption) (*Future[T], error) {
+    job, future := NewTask(fn)
+    if err := wp.Submit(job, opts...); err != nil {
+        return nil, err
+    }
+    return future, nil
+}
+
+// Context 返回任务池的context，任务池关闭完成或者Shutdown的ctx结束时被取消，长时间运行的任务可以据此提前退出
+func (wp *WorkerPool) Context() context.Context {
+    return wp.ctx
+}
+
+// Done 返回一个在所有worker退出后关闭的通道
+func (wp *WorkerPool) Done() <-chan struct{} {
+    return wp.done
+}
+
+// Shutdown 关闭任务池：不再接收新任务，按关闭策略执行或者丢弃队列中的任务，并等待正在执行的任务完成
+// 所有worker退出后返回nil；ctx先结束时返回ctx.Err()，此时任务池的context被取消，worker仍会在当前任务完成后退出
+func (wp *WorkerPool) Shutdown(ctx context.Context) error {
+    wp.mu.Lock()
+    if !wp.closed {
+        wp.closed = true
+        close(wp.quit)
+        if wp.started {
+            go func() {
+                <-wp.fed
+                wp.closeQueue(wp.opts.shutdownPolicy == ShutdownAbandon)
+                wp.wg.Wait()
+                close(wp.done)
+            }()
+        } else {
+            // 没有worker执行队列中的任务
+            wp.stopping = true
+            for _, item := range wp.queue.close(true) {
+                wp.reject(item.job, ErrPoolClosed)
+            }
+            close(wp.done)
+            go wp.discard()
+        }
+    }
+    wp.mu.Unlock()
+
+    select {
+    case <-wp.done:
+        wp.cancel()
+        return nil
+    case <-ctx.Done():
+        wp.cancel()
+        return ctx.Err()
+    }
+}
+
+// closeQueue 关闭任务队列并停止创建worker，abandon为true时丢弃队列中的任务
+func (wp *WorkerPool) closeQueue(abandon bool) {
+    wp.mu.Lock()
+    wp.stopping = true
+    // 保证队列中剩余的任务有worker执行
+    if !abandon && wp.workers == 0 && wp.queue.len() > 0 {
+        wp.spawn()
+    }
+    wp.mu.Unlock()
+    for _, item := range wp.queue.close(abandon) {
+        wp.reject(item.job, ErrPoolClosed)
+    }
+}
+
+// Stop 关闭任务池并等待所有worker退出
+func (wp *WorkerPool) Stop() {
+    _ = wp.Shutdown(context.Background())
 }
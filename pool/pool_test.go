@@ -1,7 +1,11 @@
 package pool
 
 import (
+    "context"
+    "errors"
     "fmt"
+    "sync"
+    "sync/atomic"
     "testing"
     "time"
 )
@@ -61,3 +65,576 @@ func TestWorkerPool(t *testing.T) {
     t.Logf("executed %d tasks\n", finished)
     t.Log("success")
 }
+
+// 定义一个计数任务对象
+type CountJob struct {
+    Cost    time.Duration
+    Counter *int32
+}
+
+func (j *CountJob) Do() {
+    time.Sleep(j.Cost)
+    atomic.AddInt32(j.Counter, 1)
+}
+
+func TestWorkerPoolShutdown(t *testing.T) {
+    var finished int32
+    p := NewWorkerPool(3, 3)
+    p.Run()
+    for i := 0; i < 6; i++ {
+        p.JobQueue <- &CountJob{Cost: time.Millisecond * 50, Counter: &finished}
+    }
+    if err := p.Shutdown(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    // 已经交给worker的任务都会执行完成
+    if finished != 6 {
+        t.Fatalf("expect 6 finished jobs, got %d", finished)
+    }
+    select {
+    case <-p.Done():
+    default:
+        t.Fatal("pool should be done after shutdown")
+    }
+    // 重复关闭立即返回
+    p.Stop()
+}
+
+func TestWorkerPoolShutdownTimeout(t *testing.T) {
+    var finished int32
+    p := NewWorkerPool(1, 1)
+    p.Run()
+    p.JobQueue <- &CountJob{Cost: time.Millisecond * 200, Counter: &finished}
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+    defer cancel()
+    if err := p.Shutdown(ctx); err != context.DeadlineExceeded {
+        t.Fatalf("expect deadline exceeded, got %v", err)
+    }
+    if p.Context().Err() == nil {
+        t.Fatal("pool context should be canceled")
+    }
+    <-p.Done()
+    if finished != 1 {
+        t.Fatalf("in-flight job should finish, got %d", finished)
+    }
+}
+
+func TestWorkerPoolParentContext(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    p := NewWorkerPool(2, 2, WithContext(ctx))
+    p.Run()
+    cancel()
+    select {
+    case <-p.Done():
+    case <-time.After(time.Second):
+        t.Fatal("pool should stop when parent context is canceled")
+    }
+}
+
+// 定义一个会panic的任务对象
+type PanicJob struct{}
+
+func (j *PanicJob) Do() {
+    panic("boom")
+}
+
+func TestWorkerPoolTask(t *testing.T) {
+    panics := make(chan *PanicError, 1)
+    p := NewWorkerPool(1, 1, WithPanicHandler(func(job Job, err *PanicError) {
+        panics <- err
+    }))
+    p.Run()
+    defer p.Stop()
+
+    // 普通任务发生panic时worker不会退出
+    p.JobQueue <- &PanicJob{}
+    select {
+    case err := <-panics:
+        if err.Value != "boom" || len(err.Stack) == 0 {
+            t.Fatalf("unexpected panic error: %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("panic handler should be called")
+    }
+
+    job, future := NewTask(func() (int, error) {
+        return 42, nil
+    })
+    p.JobQueue <- job
+    if v, err := future.Get(); err != nil || v != 42 {
+        t.Fatalf("unexpected result: %d, %v", v, err)
+    }
+
+    job, future = NewTask(func() (int, error) {
+        panic("task boom")
+    })
+    p.JobQueue <- job
+    _, err := future.Get()
+    var pe *PanicError
+    if !errors.As(err, &pe) || pe.Value != "task boom" {
+        t.Fatalf("expect panic error, got %v", err)
+    }
+
+    job, future = NewTask(func() (int, error) {
+        time.Sleep(time.Millisecond * 200)
+        return 1, nil
+    })
+    p.JobQueue <- job
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+    defer cancel()
+    if _, err = future.GetContext(ctx); err != context.DeadlineExceeded {
+        t.Fatalf("expect deadline exceeded, got %v", err)
+    }
+}
+
+// 定义一个阻塞直到release关闭的任务对象
+type BlockJob struct {
+    Started chan<- struct{}
+    Release <-chan struct{}
+}
+
+func (j *BlockJob) Do() {
+    if j.Started != nil {
+        j.Started <- struct{}{}
+    }
+    <-j.Release
+}
+
+// fillPool 占满唯一的worker以及容量为1的队列
+func fillPool(t *testing.T, p *WorkerPool, release <-chan struct{}) {
+    started := make(chan struct{})
+    if err := p.Submit(&BlockJob{Started: started, Release: release}); err != nil {
+        t.Fatal(err)
+    }
+    <-started
+    if err := p.Submit(&BlockJob{Release: release}); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestWorkerPoolSubmit(t *testing.T) {
+    release := make(chan struct{})
+    p := NewWorkerPool(1, 1)
+    p.Run()
+    fillPool(t, p, release)
+
+    if err := p.TrySubmit(&BlockJob{Release: release}); err != ErrQueueFull {
+        t.Fatalf("expect ErrQueueFull, got %v", err)
+    }
+    if err := p.SubmitWithTimeout(&BlockJob{Release: release}, time.Millisecond*20); err != ErrSubmitTimeout {
+        t.Fatalf("expect ErrSubmitTimeout, got %v", err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if err := p.SubmitContext(ctx, &BlockJob{Release: release}); err != context.Canceled {
+        t.Fatalf("expect context canceled, got %v", err)
+    }
+
+    close(release)
+    future, err := SubmitTask(p, func() (string, error) {
+        return "ok", nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if v, err := future.Get(); err != nil || v != "ok" {
+        t.Fatalf("unexpected result: %q, %v", v, err)
+    }
+
+    p.Stop()
+    if err = p.Submit(&BlockJob{Release: release}); err != ErrPoolClosed {
+        t.Fatalf("expect ErrPoolClosed, got %v", err)
+    }
+    if _, err = SubmitTask(p, func() (int, error) { return 0, nil }); err != ErrPoolClosed {
+        t.Fatalf("expect ErrPoolClosed, got %v", err)
+    }
+}
+
+func TestWorkerPoolRejectPolicy(t *testing.T) {
+    release := make(chan struct{})
+
+    // 拒绝新任务
+    p := NewWorkerPool(1, 1, WithRejectPolicy(RejectDropNewest))
+    p.Run()
+    fillPool(t, p, release)
+    job, future := NewTask(func() (int, error) { return 1, nil })
+    if err := p.Submit(job); err != ErrQueueFull {
+        t.Fatalf("expect ErrQueueFull, got %v", err)
+    }
+    if _, err := future.Get(); err != ErrQueueFull {
+        t.Fatalf("rejected future should get ErrQueueFull, got %v", err)
+    }
+
+    // 丢弃最早的任务
+    p2 := NewWorkerPool(1, 1, WithRejectPolicy(RejectDropOldest))
+    p2.Run()
+    started := make(chan struct{})
+    if err := p2.Submit(&BlockJob{Started: started, Release: release}); err != nil {
+        t.Fatal(err)
+    }
+    <-started
+    oldest, oldestFuture := NewTask(func() (int, error) { return 1, nil })
+    if err := p2.Submit(oldest); err != nil {
+        t.Fatal(err)
+    }
+    if err := p2.Submit(&BlockJob{Release: release}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := oldestFuture.Get(); err != ErrJobDropped {
+        t.Fatalf("oldest future should get ErrJobDropped, got %v", err)
+    }
+
+    // 由调用方执行
+    p3 := NewWorkerPool(1, 1, WithRejectPolicy(RejectCallerRuns))
+    p3.Run()
+    fillPool(t, p3, release)
+    var finished int32
+    if err := p3.Submit(&CountJob{Counter: &finished}); err != nil {
+        t.Fatal(err)
+    }
+    if atomic.LoadInt32(&finished) != 1 {
+        t.Fatal("job should run in caller goroutine")
+    }
+
+    close(release)
+    p.Stop()
+    p2.Stop()
+    p3.Stop()
+}
+
+func TestWorkerPoolShutdownAbandon(t *testing.T) {
+    release := make(chan struct{})
+    p := NewWorkerPool(1, 2, WithShutdownPolicy(ShutdownAbandon))
+    p.Run()
+    started := make(chan struct{})
+    if err := p.Submit(&BlockJob{Started: started, Release: release}); err != nil {
+        t.Fatal(err)
+    }
+    <-started
+    future, err := SubmitTask(p, func() (int, error) { return 1, nil })
+    if err != nil {
+        t.Fatal(err)
+    }
+    go func() {
+        // 等待队列关闭后再结束正在执行的任务
+        for p.queue.len() > 0 {
+            time.Sleep(time.Millisecond)
+        }
+        close(release)
+    }()
+    p.Stop()
+    if _, err = future.Get(); err != ErrPoolClosed {
+        t.Fatalf("abandoned future should get ErrPoolClosed, got %v", err)
+    }
+}
+
+// waitStats 等待任务池的状态满足条件
+func waitStats(t *testing.T, p *WorkerPool, cond func(s Stats) bool) Stats {
+    deadline := time.Now().Add(time.Second * 2)
+    for {
+        s := p.Stats()
+        if cond(s) {
+            return s
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("unexpected stats: %+v", s)
+        }
+        time.Sleep(time.Millisecond * 5)
+    }
+}
+
+func TestWorkerPoolScale(t *testing.T) {
+    release := make(chan struct{})
+    p := NewWorkerPool(1, 10, WithMaxWorkers(4), WithIdleTimeout(time.Millisecond*50))
+    p.Run()
+    defer p.Stop()
+    waitStats(t, p, func(s Stats) bool { return s.Workers == 1 && s.Idle == 1 })
+
+    // 队列中有等待的任务时扩容，最多到4个worker
+    for i := 0; i < 6; i++ {
+        if err := p.Submit(&BlockJob{Release: release}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    s := waitStats(t, p, func(s Stats) bool { return s.Active == 4 })
+    if s.Workers != 4 || s.Queued != 2 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+
+    // 空闲超时后回到最小数量
+    close(release)
+    s = waitStats(t, p, func(s Stats) bool { return s.Workers == 1 })
+    if s.Completed != 6 || s.Queued != 0 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+}
+
+func TestWorkerPoolResize(t *testing.T) {
+    release := make(chan struct{})
+    p := NewWorkerPool(2, 0, WithRejectPolicy(RejectDropNewest))
+    p.Run()
+    defer p.Stop()
+
+    if err := p.Resize(3, 1); err != ErrInvalidSize {
+        t.Fatalf("expect ErrInvalidSize, got %v", err)
+    }
+    if err := p.Resize(4, 4); err != nil {
+        t.Fatal(err)
+    }
+    waitStats(t, p, func(s Stats) bool { return s.Workers == 4 && s.Idle == 4 })
+
+    // 缩容时空闲的worker立即退出，执行中的worker在任务完成后退出
+    for i := 0; i < 3; i++ {
+        if err := p.Submit(&BlockJob{Release: release}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    waitStats(t, p, func(s Stats) bool { return s.Active == 3 })
+    if err := p.Resize(1, 1); err != nil {
+        t.Fatal(err)
+    }
+    waitStats(t, p, func(s Stats) bool { return s.Workers == 3 && s.Idle == 0 })
+    close(release)
+    s := waitStats(t, p, func(s Stats) bool { return s.Workers == 1 })
+    if s.Completed != 3 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+
+    p.Stop()
+    if err := p.Submit(&BlockJob{Release: release}); err != ErrPoolClosed {
+        t.Fatalf("expect ErrPoolClosed, got %v", err)
+    }
+    if s = p.Stats(); s.Rejected != 1 || s.Workers != 0 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+    if err := p.Resize(1, 1); err != ErrPoolClosed {
+        t.Fatalf("expect ErrPoolClosed, got %v", err)
+    }
+}
+
+// 定义一个记录执行顺序的任务对象
+type RecordJob struct {
+    Name    string
+    Cost    time.Duration
+    Mu      *sync.Mutex
+    Records *[]string
+    Running *int32
+    Overlap *int32
+}
+
+func (j *RecordJob) Do() {
+    if j.Running != nil && atomic.AddInt32(j.Running, 1) > 1 {
+        atomic.StoreInt32(j.Overlap, 1)
+    }
+    time.Sleep(j.Cost)
+    j.Mu.Lock()
+    *j.Records = append(*j.Records, j.Name)
+    j.Mu.Unlock()
+    if j.Running != nil {
+        atomic.AddInt32(j.Running, -1)
+    }
+}
+
+func TestWorkerPoolPriority(t *testing.T) {
+    var mu sync.Mutex
+    var records []string
+    release := make(chan struct{})
+    p := NewWorkerPool(1, 0)
+    p.Run()
+    started := make(chan struct{})
+    if err := p.Submit(&BlockJob{Started: started, Release: release}); err != nil {
+        t.Fatal(err)
+    }
+    <-started
+    submits := []struct {
+        name     string
+        priority Priority
+    }{
+        {"low", PriorityLow},
+        {"normal-1", PriorityNormal},
+        {"high", PriorityHigh},
+        {"normal-2", PriorityNormal},
+        {"urgent", 10},
+    }
+    for _, s := range submits {
+        if err := p.Submit(&RecordJob{Name: s.name, Mu: &mu, Records: &records}, WithJobPriority(s.priority)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    close(release)
+    p.Stop()
+    expect := "[urgent high normal-1 normal-2 low]"
+    if got := fmt.Sprint(records); got != expect {
+        t.Fatalf("expect %s, got %s", expect, got)
+    }
+}
+
+func TestWorkerPoolDropOldestPriority(t *testing.T) {
+    release := make(chan struct{})
+    p := NewWorkerPool(1, 1, WithRejectPolicy(RejectDropOldest))
+    p.Run()
+    started := make(chan struct{})
+    if err := p.Submit(&BlockJob{Started: started, Release: release}); err != nil {
+        t.Fatal(err)
+    }
+    <-started
+    if err := p.Submit(&BlockJob{Release: release}, WithJobPriority(PriorityHigh)); err != nil {
+        t.Fatal(err)
+    }
+    // 不会挤出优先级更高的任务
+    if err := p.Submit(&BlockJob{Release: release}); err != ErrQueueFull {
+        t.Fatalf("expect ErrQueueFull, got %v", err)
+    }
+    close(release)
+    p.Stop()
+}
+
+func TestWorkerPoolKeyed(t *testing.T) {
+    var mu sync.Mutex
+    var records []string
+    var running, overlap [3]int32
+    p := NewWorkerPool(4, 0)
+    p.Run()
+    for i := 0; i < 5; i++ {
+        for k := 0; k < 3; k++ {
+            job := &RecordJob{
+                Name:    fmt.Sprintf("%d-%d", k, i),
+                Cost:    time.Millisecond * 10,
+                Mu:      &mu,
+                Records: &records,
+                Running: &running[k],
+                Overlap: &overlap[k],
+            }
+            if err := p.Submit(job, WithJobKey(fmt.Sprint(k))); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    p.Stop()
+    if len(records) != 15 {
+        t.Fatalf("expect 15 records, got %d", len(records))
+    }
+    // 相同key的任务串行并且按提交顺序执行
+    next := [3]int{}
+    for _, r := range records {
+        var k, i int
+        fmt.Sscanf(r, "%d-%d", &k, &i)
+        if i != next[k] {
+            t.Fatalf("unexpected order: %v", records)
+        }
+        next[k]++
+    }
+    for k := range overlap {
+        if overlap[k] != 0 {
+            t.Fatalf("jobs with key %d run concurrently", k)
+        }
+    }
+    if s := p.Stats(); s.Completed != 15 || s.Queued != 0 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+}
+
+func TestJobQueueKeyed(t *testing.T) {
+    q := newJobQueue(3)
+    ctx := context.Background()
+    for _, item := range []*jobItem{
+        {job: &PrintJob{Seq: 1}, key: "a"},
+        {job: &PrintJob{Seq: 2}, key: "a"},
+        {job: &PrintJob{Seq: 3}, key: "b", priority: PriorityLow},
+    } {
+        if _, _, err := q.push(ctx, item, RejectDropNewest, false); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // 等待相同key的任务同样占用队列容量
+    if _, _, err := q.push(ctx, &jobItem{job: &PrintJob{Seq: 4}, key: "a"}, RejectDropNewest, false); err != ErrQueueFull {
+        t.Fatalf("expect ErrQueueFull, got %v", err)
+    }
+    first, _ := q.pop(0)
+    // key为a的任务正在执行，只能取出其它key的任务
+    second, _ := q.pop(0)
+    if first.job.(*PrintJob).Seq != 1 || second.job.(*PrintJob).Seq != 3 {
+        t.Fatalf("unexpected pop order: %v, %v", first.job, second.job)
+    }
+    if _, state := q.pop(time.Millisecond * 10); state != popTimeout {
+        t.Fatalf("job with running key should not be popped, got state %v", state)
+    }
+    q.done("a")
+    third, _ := q.pop(0)
+    if third.job.(*PrintJob).Seq != 2 {
+        t.Fatalf("unexpected pop order: %v", third.job)
+    }
+    q.done("a")
+    q.done("b")
+    if q.len() != 0 || len(q.keys) != 0 || len(q.levels) != 0 {
+        t.Fatalf("queue should be empty, got size %d, keys %d, levels %d", q.len(), len(q.keys), len(q.levels))
+    }
+}
+
+func TestJobQueuePruneLevels(t *testing.T) {
+    q := newJobQueue(0)
+    for i := 0; i < 100; i++ {
+        _, _, _ = q.push(context.Background(), &jobItem{job: &PrintJob{Seq: i}, priority: Priority(i)}, RejectBlock, true)
+        q.pop(0)
+    }
+    if len(q.levels) != 0 {
+        t.Fatalf("empty priority levels should be pruned, got %d", len(q.levels))
+    }
+}
+
+func TestWorkerPoolKeyedOrder(t *testing.T) {
+    var mu sync.Mutex
+    var records []string
+    var running, overlap int32
+    release := make(chan struct{})
+    started := make(chan struct{})
+    p := NewWorkerPool(4, 0)
+    p.Run()
+    // 先占用key，之后提交的相同key的任务都需要等待，由多个空闲的worker竞争执行
+    if err := p.Submit(&BlockJob{Started: started, Release: release}, WithJobKey("k")); err != nil {
+        t.Fatal(err)
+    }
+    <-started
+    for i := 0; i < 20; i++ {
+        job := &RecordJob{Name: fmt.Sprint(i), Mu: &mu, Records: &records, Running: &running, Overlap: &overlap}
+        if err := p.Submit(job, WithJobKey("k")); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if s := p.Stats(); s.Queued != 20 {
+        t.Fatalf("unexpected stats: %+v", s)
+    }
+    close(release)
+    p.Stop()
+    for i, r := range records {
+        if r != fmt.Sprint(i) {
+            t.Fatalf("jobs with the same key should run in submission order, got %v", records)
+        }
+    }
+    if len(records) != 20 || overlap != 0 {
+        t.Fatalf("unexpected records: %v, overlap: %d", records, overlap)
+    }
+}
+
+func TestWorkerPoolJobQueueAfterShutdown(t *testing.T) {
+    started := NewWorkerPool(1, 1)
+    started.Run()
+    started.Stop()
+    notStarted := NewWorkerPool(1, 1)
+    notStarted.Stop()
+    for _, p := range []*WorkerPool{started, notStarted} {
+        // 关闭后写入JobQueue的任务不会阻塞，并以ErrPoolClosed拒绝
+        job, future := NewTask(func() (int, error) { return 1, nil })
+        select {
+        case p.JobQueue <- job:
+        case <-time.After(time.Second):
+            t.Fatal("sending to JobQueue should not block after shutdown")
+        }
+        if _, err := future.Get(); err != ErrPoolClosed {
+            t.Fatalf("expect ErrPoolClosed, got %v", err)
+        }
+        if p.Stats().Rejected != 1 {
+            t.Fatalf("unexpected rejected count: %d", p.Stats().Rejected)
+        }
+    }
+}
@@ -0,0 +1,326 @@
+package pool
+
+import (
+    "container/list"
+    "context"
+    "sync"
+    "time"
+)
+
+// rejecter 未被执行的任务需要通知结果的任务（如NewTask创建的任务）需要实现的接口
+type rejecter interface {
+    reject(err error)
+}
+
+// rejectJob 通知任务未被执行
+func rejectJob(job Job, err error) {
+    if r, ok := job.(rejecter); ok {
+        r.reject(err)
+    }
+}
+
+// jobItem 队列中的任务
+type jobItem struct {
+    job      Job
+    priority Priority // 优先级，数值越大越先执行
+    key      string   // 不为空时，相同key的任务按顺序串行执行
+}
+
+// priorityLevel 同一优先级的任务，按写入顺序排列
+type priorityLevel struct {
+    priority Priority
+    items    *list.List
+}
+
+// jobQueue 有界的优先级任务队列，支持阻塞写入、丢弃最早的任务以及关闭
+// 带有key的任务，同一时间只有一个在优先级列表中等待或者正在执行，相同key的后续任务按写入顺序在keys中等待，
+// 等待的任务同样占用队列容量
+type jobQueue struct {
+    mu       sync.Mutex
+    levels   []*priorityLevel      // 按优先级从高到低排列，不包括空的优先级
+    keys     map[string]*list.List // 已经在优先级列表中或者正在执行的key，以及等待执行的相同key的任务
+    size     int                   // 队列中的任务数，包括等待相同key的任务
+    parked   int                   // 等待相同key的任务执行完成的任务数
+    capacity int                   // 队列容量，小于等于0表示不限制
+    closed   bool
+    pushed   chan struct{} // 写入任务或者关闭时关闭并重新创建，用于唤醒等待任务的worker
+    woken    chan struct{} // 调用wakeup时关闭并重新创建，用于通知等待任务的worker检查是否需要退出
+    popped   chan struct{} // 取出任务或者关闭时关闭并重新创建，用于唤醒等待写入的调用方
+}
+
+// newJobQueue 创建任务队列
+func newJobQueue(capacity int) *jobQueue {
+    return &jobQueue{
+        keys:     make(map[string]*list.List),
+        capacity: capacity,
+        pushed:   make(chan struct{}),
+        woken:    make(chan struct{}),
+        popped:   make(chan struct{}),
+    }
+}
+
+// full 判断队列是否已满，调用方需持有锁
+func (q *jobQueue) full() bool {
+    return q.capacity > 0 && q.size >= q.capacity
+}
+
+// level 返回指定优先级的任务列表，不存在时创建，调用方需持有锁
+func (q *jobQueue) level(p Priority) *list.List {
+    i := 0
+    for ; i < len(q.levels); i++ {
+        if q.levels[i].priority == p {
+            return q.levels[i].items
+        }
+        if q.levels[i].priority < p {
+            break
+        }
+    }
+    l := &priorityLevel{priority: p, items: list.New()}
+    q.levels = append(q.levels, nil)
+    copy(q.levels[i+1:], q.levels[i:])
+    q.levels[i] = l
+    return l.items
+}
+
+// add 写入任务，相同key的任务已经在等待或者正在执行时加入该key的等待列表，调用方需持有锁
+func (q *jobQueue) add(item *jobItem) {
+    q.size++
+    if item.key != "" {
+        if waiting, ok := q.keys[item.key]; ok {
+            waiting.PushBack(item)
+            q.parked++
+            return
+        }
+        q.keys[item.key] = list.New()
+    }
+    q.level(item.priority).PushBack(item)
+    q.notifyPushed()
+}
+
+// release 将key的下一个等待的任务加入优先级列表，没有等待的任务时释放key，调用方需持有锁
+func (q *jobQueue) release(key string) {
+    waiting, ok := q.keys[key]
+    if !ok {
+        return
+    }
+    e := waiting.Front()
+    if e == nil {
+        delete(q.keys, key)
+        return
+    }
+    item := waiting.Remove(e).(*jobItem)
+    q.parked--
+    q.level(item.priority).PushBack(item)
+    q.notifyPushed()
+}
+
+// done 带有key的任务执行完成，之后才会执行相同key的下一个任务
+func (q *jobQueue) done(key string) {
+    q.mu.Lock()
+    q.release(key)
+    q.mu.Unlock()
+}
+
+// front 返回优先级最高的最早写入的任务，调用方需持有锁
+func (q *jobQueue) front() *list.Element {
+    for _, l := range q.levels {
+        if e := l.items.Front(); e != nil {
+            return e
+        }
+    }
+    return nil
+}
+
+// lowest 返回优先级最低的非空任务列表，调用方需持有锁
+func (q *jobQueue) lowest() *priorityLevel {
+    for i := len(q.levels) - 1; i >= 0; i-- {
+        if q.levels[i].items.Len() > 0 {
+            return q.levels[i]
+        }
+    }
+    return nil
+}
+
+// remove 从优先级列表中移除任务，并删除空的优先级，调用方需持有锁
+func (q *jobQueue) remove(e *list.Element) *jobItem {
+    item := e.Value.(*jobItem)
+    for i, l := range q.levels {
+        if l.priority != item.priority {
+            continue
+        }
+        l.items.Remove(e)
+        if l.items.Len() == 0 {
+            q.levels = append(q.levels[:i], q.levels[i+1:]...)
+        }
+        break
+    }
+    q.size--
+    return item
+}
+
+// notifyPushed 唤醒所有等待任务的worker，调用方需持有锁
+func (q *jobQueue) notifyPushed() {
+    close(q.pushed)
+    q.pushed = make(chan struct{})
+}
+
+// notifyPopped 唤醒所有等待写入的调用方，调用方需持有锁
+func (q *jobQueue) notifyPopped() {
+    close(q.popped)
+    q.popped = make(chan struct{})
+}
+
+// push 写入任务，队列已满时按policy处理，wait为true时RejectBlock策略会等待队列空闲直到ctx结束
+// 返回需要由调用方直接执行的任务（RejectCallerRuns）以及被挤出队列的任务（RejectDropOldest）
+// RejectDropOldest只会挤出优先级不高于新任务的任务，否则返回ErrQueueFull
+// RejectCallerRuns返回的带有key的任务会占用该key，执行完成后需要调用done；相同key的任务已经在队列中时按RejectBlock处理
+func (q *jobQueue) push(ctx context.Context, item *jobItem, policy RejectPolicy, wait bool) (callerJob *jobItem, dropped *jobItem, err error) {
+    for {
+        q.mu.Lock()
+        if q.closed {
+            q.mu.Unlock()
+            return nil, nil, ErrPoolClosed
+        }
+        if !q.full() {
+            q.add(item)
+            q.mu.Unlock()
+            return nil, nil, nil
+        }
+        switch policy {
+        case RejectDropNewest:
+            q.mu.Unlock()
+            return nil, nil, ErrQueueFull
+        case RejectDropOldest:
+            l := q.lowest()
+            if l == nil || l.priority > item.priority {
+                q.mu.Unlock()
+                return nil, nil, ErrQueueFull
+            }
+            oldest := q.remove(l.items.Front())
+            if oldest.key != "" {
+                q.release(oldest.key)
+            }
+            q.add(item)
+            q.mu.Unlock()
+            return nil, oldest, nil
+        case RejectCallerRuns:
+            if item.key == "" {
+                q.mu.Unlock()
+                return item, nil, nil
+            }
+            // 由调用方执行时需要保证相同key的任务的执行顺序
+            if _, ok := q.keys[item.key]; !ok {
+                q.keys[item.key] = list.New()
+                q.mu.Unlock()
+                return item, nil, nil
+            }
+        }
+        popped := q.popped
+        q.mu.Unlock()
+        if !wait {
+            return nil, nil, ErrQueueFull
+        }
+        select {
+        case <-popped:
+        case <-ctx.Done():
+            return nil, nil, ctx.Err()
+        }
+    }
+}
+
+// popState pop的返回状态
+type popState int
+
+const (
+    popJob     popState = iota // 取到任务
+    popWakeup                  // 被wakeup唤醒
+    popTimeout                 // 等待超时
+    popClosed                  // 队列已关闭并且为空
+)
+
+// pop 取出优先级最高的任务，队列为空时等待，直到有新任务、被wakeup唤醒、等待超过idle（大于0时）或者队列关闭
+// 取出带有key的任务后，相同key的任务在调用done之前不会被取出
+func (q *jobQueue) pop(idle time.Duration) (*jobItem, popState) {
+    var timeout <-chan time.Time
+    if idle > 0 {
+        timer := time.NewTimer(idle)
+        defer timer.Stop()
+        timeout = timer.C
+    }
+    for {
+        q.mu.Lock()
+        if e := q.front(); e != nil {
+            item := q.remove(e)
+            q.notifyPopped()
+            q.mu.Unlock()
+            return item, popJob
+        }
+        // 仍有等待相同key的任务时，需要等待正在执行的任务完成
+        if q.closed && q.size == 0 {
+            q.mu.Unlock()
+            return nil, popClosed
+        }
+        pushed := q.pushed
+        woken := q.woken
+        q.mu.Unlock()
+        select {
+        case <-pushed:
+        case <-woken:
+            return nil, popWakeup
+        case <-timeout:
+            return nil, popTimeout
+        }
+    }
+}
+
+// wakeup 唤醒所有等待任务的worker，用于通知worker检查是否需要退出
+func (q *jobQueue) wakeup() {
+    q.mu.Lock()
+    close(q.woken)
+    q.woken = make(chan struct{})
+    q.mu.Unlock()
+}
+
+// len 返回队列中的任务数，包括等待相同key的任务
+func (q *jobQueue) len() int {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    return q.size
+}
+
+// ready 返回可以立即被取出的任务数，不包括等待相同key的任务
+func (q *jobQueue) ready() int {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    return q.size - q.parked
+}
+
+// close 关闭队列，不再接收任务，abandon为true时丢弃并返回队列中的任务，否则等待worker取完
+func (q *jobQueue) close(abandon bool) []*jobItem {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    if q.closed {
+        return nil
+    }
+    q.closed = true
+    var abandoned []*jobItem
+    if abandon {
+        for _, l := range q.levels {
+            for e := l.items.Front(); e != nil; e = e.Next() {
+                abandoned = append(abandoned, e.Value.(*jobItem))
+            }
+        }
+        for _, waiting := range q.keys {
+            for e := waiting.Front(); e != nil; e = e.Next() {
+                abandoned = append(abandoned, e.Value.(*jobItem))
+            }
+        }
+        q.levels = nil
+        q.keys = make(map[string]*list.List)
+        q.size = 0
+        q.parked = 0
+    }
+    q.notifyPushed()
+    q.notifyPopped()
+    return abandoned
+}
@@ -0,0 +1,121 @@
+package pool
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "runtime/debug"
+)
+
+// PanicError 任务执行过程中发生的panic，包含panic的值以及发生时的调用栈
+type PanicError struct {
+    Value interface{}
+    Stack []byte
+}
+
+// newPanicError 根据recover的值创建PanicError，需要在defer中调用以获取正确的调用栈
+func newPanicError(v interface{}) *PanicError {
+    return &PanicError{
+        Value: v,
+        Stack: debug.Stack(),
+    }
+}
+
+func (e *PanicError) Error() string {
+    return fmt.Sprintf("pool: job panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// PanicHandler 处理任务中发生的panic，调用后worker会继续执行后续任务
+type PanicHandler func(job Job, err *PanicError)
+
+// defaultPanicHandler 默认的panic处理方法，将panic信息以及调用栈输出到标准日志
+func defaultPanicHandler(job Job, err *PanicError) {
+    log.Printf("%T: %v", job, err)
+}
+
+// safeDo 执行任务，任务发生panic时交给handler处理，避免worker退出
+func safeDo(job Job, handler PanicHandler) {
+    defer func() {
+        if v := recover(); v != nil {
+            if handler == nil {
+                handler = defaultPanicHandler
+            }
+            handler(job, newPanicError(v))
+        }
+    }()
+    job.Do()
+}
+
+// Future 异步任务的执行结果
+type Future[T any] struct {
+    done  chan struct{}
+    value T
+    err   error
+}
+
+// newFuture 创建一个未完成的Future
+func newFuture[T any]() *Future[T] {
+    return &Future[T]{done: make(chan struct{})}
+}
+
+// complete 设置任务结果，只能调用一次
+func (f *Future[T]) complete(value T, err error) {
+    f.value = value
+    f.err = err
+    close(f.done)
+}
+
+// Done 返回一个在任务完成后关闭的通道
+func (f *Future[T]) Done() <-chan struct{} {
+    return f.done
+}
+
+// Get 等待任务完成并返回结果，任务发生panic时返回*PanicError
+func (f *Future[T]) Get() (T, error) {
+    <-f.done
+    return f.value, f.err
+}
+
+// GetContext 等待任务完成并返回结果，ctx先结束时返回ctx.Err()，任务仍会继续执行
+func (f *Future[T]) GetContext(ctx context.Context) (T, error) {
+    select {
+    case <-f.done:
+        return f.value, f.err
+    case <-ctx.Done():
+        var zero T
+        return zero, ctx.Err()
+    }
+}
+
+// task 带有返回值的任务
+type task[T any] struct {
+    fn     func() (T, error)
+    future *Future[T]
+}
+
+// reject 任务未被执行，将err作为任务的结果
+func (t *task[T]) reject(err error) {
+    var zero T
+    t.future.complete(zero, err)
+}
+
+// Do 执行任务并设置结果，发生panic时结果为*PanicError
+func (t *task[T]) Do() {
+    defer func() {
+        if v := recover(); v != nil {
+            var zero T
+            t.future.complete(zero, newPanicError(v))
+        }
+    }()
+    value, err := t.fn()
+    t.future.complete(value, err)
+}
+
+// NewTask 将带有返回值的方法包装为Job，返回的Future用于等待执行结果
+func NewTask[T any](fn func() (T, error)) (Job, *Future[T]) {
+    t := &task[T]{
+        fn:     fn,
+        future: newFuture[T](),
+    }
+    return t, t.future
+}
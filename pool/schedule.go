@@ -0,0 +1,355 @@
+package pool
+
+import (
+    "container/heap"
+    "errors"
+    "sync"
+    "time"
+)
+
+var (
+    // ErrJobCanceled 定时任务在执行前被取消
+    ErrJobCanceled = errors.New("pool: scheduled job canceled")
+    // ErrInvalidPeriod 周期任务的间隔不合法
+    ErrInvalidPeriod = errors.New("pool: invalid schedule period")
+)
+
+// scheduleKind 定时任务的类型
+type scheduleKind int
+
+const (
+    scheduleOnce       scheduleKind = iota // 只执行一次
+    scheduleFixedRate                      // 按固定频率执行
+    scheduleFixedDelay                     // 上一次执行完成后间隔固定时间再执行
+    scheduleCron                           // 按cron表达式执行
+)
+
+// ScheduledJob 定时任务的句柄，用于取消任务以及等待任务结束
+type ScheduledJob struct {
+    s        *scheduler
+    job      Job
+    opts     []SubmitOption
+    kind     scheduleKind
+    period   time.Duration
+    cron     *CronSchedule
+    next     time.Time // 下一次执行的时间
+    index    int       // 在堆中的位置，不在堆中时为-1
+    running  bool      // 是否已经提交到任务池并且尚未执行完成
+    canceled bool
+    once     sync.Once
+    done     chan struct{}
+}
+
+// Cancel 取消定时任务，尚未执行的单次任务不再执行，周期任务不再安排后续的执行，正在执行的任务会继续执行完成
+// 任务已经结束或者已经取消时返回false
+func (sj *ScheduledJob) Cancel() bool {
+    s := sj.s
+    s.mu.Lock()
+    if sj.canceled || sj.finished() {
+        s.mu.Unlock()
+        return false
+    }
+    sj.canceled = true
+    if sj.index >= 0 {
+        heap.Remove(&s.entries, sj.index)
+    }
+    // 正在执行的任务在执行完成后由complete结束
+    running := sj.running
+    s.mu.Unlock()
+    if !running {
+        sj.finish(ErrJobCanceled)
+    }
+    return true
+}
+
+// Done 返回一个在任务结束后关闭的通道：单次任务执行完成、任务被取消、周期任务没有后续的执行时间或者任务池关闭
+func (sj *ScheduledJob) Done() <-chan struct{} {
+    return sj.done
+}
+
+// Next 返回下一次执行的时间
+func (sj *ScheduledJob) Next() time.Time {
+    sj.s.mu.Lock()
+    defer sj.s.mu.Unlock()
+    return sj.next
+}
+
+// finished 判断任务是否已经结束
+func (sj *ScheduledJob) finished() bool {
+    select {
+    case <-sj.done:
+        return true
+    default:
+        return false
+    }
+}
+
+// finish 结束任务，err不为nil时通知单次任务未被执行
+func (sj *ScheduledJob) finish(err error) {
+    sj.once.Do(func() {
+        close(sj.done)
+        if err != nil && sj.kind == scheduleOnce {
+            rejectJob(sj.job, err)
+        }
+    })
+}
+
+// reschedule 计算周期任务下一次执行的时间，返回false表示没有后续的执行
+func (sj *ScheduledJob) reschedule(now time.Time) bool {
+    switch sj.kind {
+    case scheduleFixedRate:
+        // 跳过已经错过的执行时间
+        missed := now.Sub(sj.next)/sj.period + 1
+        sj.next = sj.next.Add(missed * sj.period)
+    case scheduleFixedDelay:
+        sj.next = now.Add(sj.period)
+    case scheduleCron:
+        sj.next = sj.cron.Next(now)
+        return !sj.next.IsZero()
+    default:
+        return false
+    }
+    return true
+}
+
+// scheduledRun 提交到任务池的定时任务
+type scheduledRun struct {
+    sj *ScheduledJob
+}
+
+// Do 执行任务，执行前已经被取消的任务不再执行，按固定间隔执行的任务在完成后安排下一次执行
+func (r *scheduledRun) Do() {
+    sj := r.sj
+    s := sj.s
+    s.mu.Lock()
+    canceled := sj.canceled
+    s.mu.Unlock()
+    if canceled {
+        s.complete(sj, ErrJobCanceled)
+        return
+    }
+    defer s.complete(sj, nil)
+    safeDo(sj.job, s.wp.opts.panicHandler)
+}
+
+// reject 任务未被任务池接收
+func (r *scheduledRun) reject(err error) {
+    r.sj.s.complete(r.sj, err)
+}
+
+// scheduleHeap 按执行时间排序的定时任务
+type scheduleHeap []*ScheduledJob
+
+func (h scheduleHeap) Len() int           { return len(h) }
+func (h scheduleHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+func (h scheduleHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+
+func (h *scheduleHeap) Push(x interface{}) {
+    sj := x.(*ScheduledJob)
+    sj.index = len(*h)
+    *h = append(*h, sj)
+}
+
+func (h *scheduleHeap) Pop() interface{} {
+    old := *h
+    n := len(old)
+    sj := old[n-1]
+    old[n-1] = nil
+    sj.index = -1
+    *h = old[:n-1]
+    return sj
+}
+
+// scheduler 任务池的定时器，到期后将任务提交到任务池
+type scheduler struct {
+    wp      *WorkerPool
+    mu      sync.Mutex
+    entries scheduleHeap
+    stopped bool // 任务池已经关闭，不再接收定时任务
+    wake    chan struct{}
+}
+
+// newScheduler 创建并启动定时器，任务池关闭时退出
+func newScheduler(wp *WorkerPool) *scheduler {
+    s := &scheduler{
+        wp:   wp,
+        wake: make(chan struct{}, 1),
+    }
+    go s.loop()
+    return s
+}
+
+// add 加入定时任务并唤醒定时器，调用方需持有锁
+func (s *scheduler) add(sj *ScheduledJob) {
+    heap.Push(&s.entries, sj)
+    select {
+    case s.wake <- struct{}{}:
+    default:
+    }
+}
+
+// loop 等待最早的任务到期并提交到任务池
+func (s *scheduler) loop() {
+    timer := time.NewTimer(time.Hour)
+    defer timer.Stop()
+    for {
+        var due []*ScheduledJob
+        wait := time.Hour
+        now := time.Now()
+        s.mu.Lock()
+        for s.entries.Len() > 0 && !s.entries[0].next.After(now) {
+            sj := s.entries[0]
+            // 上一次执行尚未完成时跳过本次执行
+            if !sj.running {
+                sj.running = true
+                due = append(due, sj)
+            }
+            if sj.kind == scheduleFixedRate || sj.kind == scheduleCron {
+                if sj.reschedule(now) {
+                    heap.Fix(&s.entries, 0)
+                    continue
+                }
+            }
+            heap.Pop(&s.entries)
+        }
+        if s.entries.Len() > 0 {
+            wait = s.entries[0].next.Sub(now)
+        }
+        s.mu.Unlock()
+
+        for _, sj := range due {
+            go s.submit(sj)
+        }
+        if !timer.Stop() {
+            select {
+            case <-timer.C:
+            default:
+            }
+        }
+        timer.Reset(wait)
+        select {
+        case <-timer.C:
+        case <-s.wake:
+        case <-s.wp.quit:
+            s.stop()
+            return
+        }
+    }
+}
+
+// submit 将到期的任务提交到任务池
+func (s *scheduler) submit(sj *ScheduledJob) {
+    _ = s.wp.Submit(&scheduledRun{sj: sj}, sj.opts...)
+}
+
+// complete 任务执行完成或者未被执行，安排按固定间隔执行的任务的下一次执行
+func (s *scheduler) complete(sj *ScheduledJob, err error) {
+    s.mu.Lock()
+    sj.running = false
+    if err == nil && !sj.canceled && !s.stopped && sj.kind == scheduleFixedDelay {
+        sj.reschedule(time.Now())
+        s.add(sj)
+        s.mu.Unlock()
+        return
+    }
+    // 周期任务仍在堆中等待下一次执行
+    pending := sj.index >= 0
+    s.mu.Unlock()
+    if pending {
+        return
+    }
+    sj.finish(err)
+}
+
+// stop 任务池关闭时结束所有等待中的定时任务
+func (s *scheduler) stop() {
+    s.mu.Lock()
+    s.stopped = true
+    entries := s.entries
+    s.entries = nil
+    for _, sj := range entries {
+        sj.index = -1
+    }
+    s.mu.Unlock()
+    for _, sj := range entries {
+        sj.finish(ErrPoolClosed)
+    }
+}
+
+// schedule 创建定时任务
+func (wp *WorkerPool) schedule(job Job, kind scheduleKind, next time.Time, period time.Duration, cron *CronSchedule, opts []SubmitOption) (*ScheduledJob, error) {
+    wp.mu.Lock()
+    if wp.closed {
+        wp.mu.Unlock()
+        return nil, ErrPoolClosed
+    }
+    if wp.sched == nil {
+        wp.sched = newScheduler(wp)
+    }
+    s := wp.sched
+    wp.mu.Unlock()
+
+    sj := &ScheduledJob{
+        s:      s,
+        job:    job,
+        opts:   opts,
+        kind:   kind,
+        period: period,
+        cron:   cron,
+        next:   next,
+        index:  -1,
+        done:   make(chan struct{}),
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.stopped {
+        return nil, ErrPoolClosed
+    }
+    s.add(sj)
+    return sj, nil
+}
+
+// ScheduleAt 在指定的时间将任务提交到任务池执行，t已经过去时立即执行
+func (wp *WorkerPool) ScheduleAt(job Job, t time.Time, opts ...SubmitOption) (*ScheduledJob, error) {
+    return wp.schedule(job, scheduleOnce, t, 0, nil, opts)
+}
+
+// ScheduleAfter 在delay之后将任务提交到任务池执行
+func (wp *WorkerPool) ScheduleAfter(job Job, delay time.Duration, opts ...SubmitOption) (*ScheduledJob, error) {
+    return wp.schedule(job, scheduleOnce, time.Now().Add(delay), 0, nil, opts)
+}
+
+// ScheduleAtFixedRate 在initialDelay之后开始，每隔period执行一次任务
+// 上一次执行尚未完成时跳过本次执行，错过的执行时间不会补偿
+func (wp *WorkerPool) ScheduleAtFixedRate(job Job, initialDelay, period time.Duration, opts ...SubmitOption) (*ScheduledJob, error) {
+    if period <= 0 {
+        return nil, ErrInvalidPeriod
+    }
+    return wp.schedule(job, scheduleFixedRate, time.Now().Add(initialDelay), period, nil, opts)
+}
+
+// ScheduleWithFixedDelay 在initialDelay之后开始执行任务，每次执行完成后间隔delay再执行下一次
+func (wp *WorkerPool) ScheduleWithFixedDelay(job Job, initialDelay, delay time.Duration, opts ...SubmitOption) (*ScheduledJob, error) {
+    if delay <= 0 {
+        return nil, ErrInvalidPeriod
+    }
+    return wp.schedule(job, scheduleFixedDelay, time.Now().Add(initialDelay), delay, nil, opts)
+}
+
+// ScheduleCron 按cron表达式执行任务，表达式的格式参考ParseCron，使用本地时区
+// 上一次执行尚未完成时跳过本次执行
+func (wp *WorkerPool) ScheduleCron(job Job, spec string, opts ...SubmitOption) (*ScheduledJob, error) {
+    cron, err := ParseCron(spec)
+    if err != nil {
+        return nil, err
+    }
+    next := cron.Next(time.Now())
+    if next.IsZero() {
+        return nil, ErrInvalidPeriod
+    }
+    return wp.schedule(job, scheduleCron, next, 0, cron, opts)
+}
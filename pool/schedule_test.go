@@ -0,0 +1,179 @@
+package pool
+
+import (
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestParseCron(t *testing.T) {
+    loc := time.FixedZone("UTC+8", 8*3600)
+    base := time.Date(2024, 1, 31, 10, 15, 30, 0, loc) // 周三
+    cases := []struct {
+        spec   string
+        expect time.Time
+    }{
+        {"* * * * *", time.Date(2024, 1, 31, 10, 16, 0, 0, loc)},
+        {"*/10 * * * * *", time.Date(2024, 1, 31, 10, 15, 40, 0, loc)},
+        {"0 9-18/3 * * *", time.Date(2024, 1, 31, 12, 0, 0, 0, loc)},
+        {"30 8 * * 1,5", time.Date(2024, 2, 2, 8, 30, 0, 0, loc)},
+        {"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, loc)},
+        {"0 0 1 * 7", time.Date(2024, 2, 1, 0, 0, 0, 0, loc)}, // 日和周满足任意一个
+        {"@daily", time.Date(2024, 2, 1, 0, 0, 0, 0, loc)},
+        {"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, loc)},
+    }
+    for _, c := range cases {
+        s, err := ParseCron(c.spec)
+        if err != nil {
+            t.Fatalf("parse %q: %v", c.spec, err)
+        }
+        if next := s.Next(base); !next.Equal(c.expect) {
+            t.Errorf("%q: expect %s, got %s", c.spec, c.expect, next)
+        }
+    }
+    for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+        if _, err := ParseCron(spec); err == nil {
+            t.Errorf("%q should be invalid", spec)
+        }
+    }
+    // 不存在的日期
+    s, _ := ParseCron("0 0 30 2 *")
+    if next := s.Next(base); !next.IsZero() {
+        t.Errorf("expect zero time, got %s", next)
+    }
+}
+
+func TestWorkerPoolSchedule(t *testing.T) {
+    p := NewWorkerPool(2, 10)
+    p.Run()
+    defer p.Stop()
+
+    start := time.Now()
+    job, future := NewTask(func() (time.Time, error) {
+        return time.Now(), nil
+    })
+    sj, err := p.ScheduleAfter(job, time.Millisecond*50)
+    if err != nil {
+        t.Fatal(err)
+    }
+    ran, err := future.Get()
+    if err != nil || ran.Sub(start) < time.Millisecond*50 {
+        t.Fatalf("job should run after delay: %s, %v", ran.Sub(start), err)
+    }
+    <-sj.Done()
+    if sj.Cancel() {
+        t.Fatal("finished job should not be canceled")
+    }
+
+    // 执行前取消
+    job, future = NewTask(func() (time.Time, error) {
+        return time.Now(), nil
+    })
+    if sj, err = p.ScheduleAt(job, time.Now().Add(time.Hour)); err != nil {
+        t.Fatal(err)
+    }
+    if !sj.Cancel() {
+        t.Fatal("pending job should be canceled")
+    }
+    if _, err = future.Get(); err != ErrJobCanceled {
+        t.Fatalf("expect ErrJobCanceled, got %v", err)
+    }
+}
+
+func TestWorkerPoolScheduleRecurring(t *testing.T) {
+    p := NewWorkerPool(2, 10)
+    p.Run()
+
+    if _, err := p.ScheduleAtFixedRate(&CountJob{}, 0, 0); err != ErrInvalidPeriod {
+        t.Fatalf("expect ErrInvalidPeriod, got %v", err)
+    }
+    var rate, delay int32
+    rateJob, err := p.ScheduleAtFixedRate(&CountJob{Counter: &rate}, 0, time.Millisecond*20)
+    if err != nil {
+        t.Fatal(err)
+    }
+    delayJob, err := p.ScheduleWithFixedDelay(&CountJob{Cost: time.Millisecond * 20, Counter: &delay}, 0, time.Millisecond*20)
+    if err != nil {
+        t.Fatal(err)
+    }
+    time.Sleep(time.Millisecond * 210)
+    if !rateJob.Cancel() {
+        t.Fatal("recurring job should be canceled")
+    }
+    <-rateJob.Done()
+    n := atomic.LoadInt32(&rate)
+    // 固定频率约执行10次，固定间隔（执行20ms + 间隔20ms）约执行5次
+    if n < 5 || n > 12 {
+        t.Fatalf("unexpected fixed rate executions: %d", n)
+    }
+    if d := atomic.LoadInt32(&delay); d < 3 || d > 6 {
+        t.Fatalf("unexpected fixed delay executions: %d", d)
+    }
+    time.Sleep(time.Millisecond * 50)
+    if atomic.LoadInt32(&rate) > n+1 {
+        t.Fatal("canceled job should not run again")
+    }
+
+    // 关闭任务池时结束所有定时任务
+    p.Stop()
+    select {
+    case <-delayJob.Done():
+    case <-time.After(time.Second):
+        t.Fatal("scheduled job should be done after pool stopped")
+    }
+    if _, err = p.ScheduleAfter(&CountJob{}, 0); err != ErrPoolClosed {
+        t.Fatalf("expect ErrPoolClosed, got %v", err)
+    }
+}
+
+func TestWorkerPoolScheduleCancelRunning(t *testing.T) {
+    p := NewWorkerPool(1, 10)
+    p.Run()
+    defer p.Stop()
+
+    started := make(chan struct{}, 1)
+    release := make(chan struct{})
+    sj, err := p.ScheduleAtFixedRate(&BlockJob{Started: started, Release: release}, 0, time.Millisecond*10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    <-started
+    if !sj.Cancel() {
+        t.Fatal("running job should be canceled")
+    }
+    // 正在执行的任务完成之前任务还没有结束
+    select {
+    case <-sj.Done():
+        t.Fatal("job should not be done while running")
+    case <-time.After(time.Millisecond * 50):
+    }
+    close(release)
+    select {
+    case <-sj.Done():
+    case <-time.After(time.Second):
+        t.Fatal("job should be done after the running execution finished")
+    }
+}
+
+func TestWorkerPoolScheduleCron(t *testing.T) {
+    var finished int32
+    p := NewWorkerPool(1, 10)
+    p.Run()
+    defer p.Stop()
+    sj, err := p.ScheduleCron(&CountJob{Counter: &finished}, "* * * * * *")
+    if err != nil {
+        t.Fatal(err)
+    }
+    next := sj.Next()
+    if next.Before(time.Now()) || next.Sub(time.Now()) > time.Second {
+        t.Fatalf("unexpected next time: %s", next)
+    }
+    time.Sleep(time.Until(next) + time.Millisecond*1100)
+    sj.Cancel()
+    if n := atomic.LoadInt32(&finished); n < 1 || n > 3 {
+        t.Fatalf("unexpected cron executions: %d", n)
+    }
+    if _, err = p.ScheduleCron(&CountJob{}, "bad spec"); err == nil {
+        t.Fatal("invalid spec should fail")
+    }
+}